@@ -0,0 +1,232 @@
+package icns
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/jackmordaunt/icns/v3/internal/paint"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+)
+
+// BadgeShape is the shape of a Badge.
+type BadgeShape int
+
+// BadgeShape constants.
+const (
+	// BadgeCorner is a pill inset from the corner.
+	BadgeCorner BadgeShape = iota
+	// BadgeRibbon is a band across the corner at 45 degrees.
+	BadgeRibbon
+)
+
+// Corner is a corner of the icon.
+type Corner int
+
+// Corner constants.
+const (
+	TopRight Corner = iota
+	TopLeft
+	BottomRight
+	BottomLeft
+)
+
+// Badge is a Filter that overlays a label, such as a build channel, on a
+// corner of the source.
+type Badge struct {
+	// Text is the label, rendered in Go Bold.
+	Text string
+	// Shape of the badge.
+	Shape BadgeShape
+	// Corner the badge is placed in.
+	Corner Corner
+	// Color fills the badge. Defaults to red if nil.
+	Color color.Color
+	// TextColor of the label. Defaults to white if nil.
+	TextColor color.Color
+	// Scale is the height of the badge as a fraction of the source side.
+	// Defaults to a quarter, which keeps short labels legible at 32px.
+	Scale float64
+}
+
+// Badge defaults.
+var (
+	badgeColor     = color.NRGBA{R: 0xe5, G: 0x39, B: 0x35, A: 0xff}
+	badgeTextColor = color.White
+)
+
+const (
+	badgeScale = 0.25
+	// badgeInset is the space between a corner badge and the edges, as a
+	// fraction of the source side.
+	badgeInset = 0.04
+	// badgeText is the cap height of the label as a fraction of the badge
+	// height.
+	badgeText = 0.5
+)
+
+// Apply draws the badge onto a copy of img. The copy is returned without the
+// badge if the label can't be rendered; an Encoder reports the error instead.
+func (b Badge) Apply(img image.Image) image.Image {
+	dst, err := b.apply(img)
+	if err != nil {
+		return cloneImage(img)
+	}
+	return dst
+}
+
+// apply draws the badge onto a copy of img.
+func (b Badge) apply(img image.Image) (image.Image, error) {
+	dst := cloneImage(img)
+	if b.Text == "" {
+		return dst, nil
+	}
+	var (
+		side  = float64(dst.Bounds().Dx())
+		scale = b.Scale
+	)
+	if dst.Bounds().Dy() > dst.Bounds().Dx() {
+		side = float64(dst.Bounds().Dy())
+	}
+	if scale <= 0 {
+		scale = badgeScale
+	}
+	label, err := b.label(side * scale)
+	if err != nil {
+		return nil, fmt.Errorf("badge: %w", err)
+	}
+	switch b.Shape {
+	case BadgeRibbon:
+		b.ribbon(dst, label)
+	default:
+		b.corner(dst, label)
+	}
+	return dst, nil
+}
+
+// label renders the text horizontally on a band of the badge colour with the
+// given height, just long enough for the text and its padding.
+func (b Badge) label(height float64) (*image.NRGBA, error) {
+	face, err := paint.Bold(height * badgeText)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		width   = font.MeasureString(face, b.Text)
+		h       = int(math.Ceil(height))
+		padding = int(math.Ceil(height / 2))
+		w       = width.Ceil() + padding*2
+		dst     = image.NewNRGBA(image.Rect(0, 0, w, h))
+	)
+	fill, text := b.Color, b.TextColor
+	if fill == nil {
+		fill = badgeColor
+	}
+	if text == nil {
+		text = badgeTextColor
+	}
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+	capHeight := face.Metrics().CapHeight.Ceil()
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(text),
+		Face: face,
+		Dot:  fixed.P(padding, (h+capHeight)/2),
+	}
+	d.DrawString(b.Text)
+	return dst, nil
+}
+
+// corner draws label as a pill inset from the corner, shrinking it if it is
+// wider than the image.
+func (b Badge) corner(dst draw.Image, label *image.NRGBA) {
+	var (
+		bounds = dst.Bounds()
+		inset  = int(math.Round(float64(bounds.Dx()) * badgeInset))
+		w, h   = label.Rect.Dx(), label.Rect.Dy()
+		max    = bounds.Dx() - inset*2
+	)
+	if w > max {
+		scaled := image.NewNRGBA(image.Rect(0, 0, max, h*max/w))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), label, label.Bounds(), xdraw.Src, nil)
+		label, w, h = scaled, max, scaled.Rect.Dy()
+	}
+	min := image.Pt(bounds.Max.X-inset-w, bounds.Min.Y+inset)
+	switch b.Corner {
+	case TopLeft:
+		min.X = bounds.Min.X + inset
+	case BottomRight:
+		min.Y = bounds.Max.Y - inset - h
+	case BottomLeft:
+		min = image.Pt(bounds.Min.X+inset, bounds.Max.Y-inset-h)
+	}
+	var (
+		r      = image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+		radius = float64(h) / 2
+	)
+	if w < h {
+		radius = float64(w) / 2
+	}
+	draw.DrawMask(dst, r, label, image.Point{}, paint.RoundedRect(w, h, radius), image.Point{}, draw.Over)
+}
+
+// ribbon draws label as a band across the corner at 45 degrees, placed far
+// enough from the corner for the text to fit inside the image.
+func (b Badge) ribbon(dst draw.Image, label *image.NRGBA) {
+	var (
+		bounds = dst.Bounds()
+		side   = float64(bounds.Dx())
+		w, h   = float64(label.Rect.Dx()), float64(label.Rect.Dy())
+		// The edge of the band nearest the corner is a chord of length
+		// 2*distance, where distance is measured along the diagonal.
+		distance = w/2 + h/2
+		limit    = side / 2 / math.Sqrt2
+	)
+	if distance > limit {
+		// Shrink the label so that the band stays within the near half of
+		// the image.
+		f := limit / distance
+		scaled := image.NewNRGBA(image.Rect(0, 0, int(w*f), int(h*f)))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), label, label.Bounds(), xdraw.Src, nil)
+		label, w, h, distance = scaled, w*f, h*f, limit
+	}
+	// Extend the band beyond the edges of the image so that only the image
+	// bounds clip it.
+	var (
+		band = image.NewNRGBA(image.Rect(0, 0, int(w+h*4), int(h)))
+		fill = b.Color
+	)
+	if fill == nil {
+		fill = badgeColor
+	}
+	draw.Draw(band, band.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+	draw.Draw(band, label.Bounds().Add(image.Pt(int(h*2), 0)), label, image.Point{}, draw.Src)
+	var (
+		// Centre of the band, offset from the corner along the diagonal.
+		offset = (distance + h/2) / math.Sqrt2
+		cx, cy = float64(bounds.Max.X) - offset, float64(bounds.Min.Y) + offset
+		angle  = math.Pi / 4
+	)
+	switch b.Corner {
+	case TopLeft:
+		cx, angle = float64(bounds.Min.X)+offset, -math.Pi/4
+	case BottomRight:
+		cy, angle = float64(bounds.Max.Y)-offset, -math.Pi/4
+	case BottomLeft:
+		cx, cy = float64(bounds.Min.X)+offset, float64(bounds.Max.Y)-offset
+	}
+	var (
+		sin, cos = math.Sincos(angle)
+		sx, sy   = float64(band.Rect.Dx()) / 2, float64(band.Rect.Dy()) / 2
+		s2d      = f64.Aff3{
+			cos, -sin, cx - cos*sx + sin*sy,
+			sin, cos, cy - sin*sx - cos*sy,
+		}
+	)
+	xdraw.BiLinear.Transform(dst, s2d, band, band.Bounds(), xdraw.Over, nil)
+}
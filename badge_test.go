@@ -0,0 +1,127 @@
+package icns
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBadge(t *testing.T) {
+	t.Parallel()
+	var (
+		fill = color.NRGBA{B: 0xff, A: 0xff}
+		text = color.NRGBA{G: 0xff, A: 0xff}
+		src  = opaqueImage(256)
+	)
+	// count returns the number of pixels in r matching c.
+	count := func(img image.Image, r image.Rectangle, c color.NRGBA) int {
+		var n int
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if color.NRGBAModel.Convert(img.At(x, y)) == c {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	var (
+		top    = image.Rect(0, 0, 256, 128)
+		bottom = image.Rect(0, 128, 256, 256)
+		left   = image.Rect(0, 0, 128, 256)
+		right  = image.Rect(128, 0, 256, 256)
+	)
+	tests := []struct {
+		desc  string
+		badge Badge
+		// in and out are regions expected to hold and be free of the badge.
+		in, out []image.Rectangle
+	}{
+		{
+			"corner top right",
+			Badge{Text: "B", Corner: TopRight},
+			[]image.Rectangle{top.Intersect(right)},
+			[]image.Rectangle{bottom, left},
+		},
+		{
+			"corner bottom left",
+			Badge{Text: "B", Corner: BottomLeft},
+			[]image.Rectangle{bottom.Intersect(left)},
+			[]image.Rectangle{top, right},
+		},
+		{
+			"ribbon top left",
+			Badge{Text: "BETA", Shape: BadgeRibbon, Corner: TopLeft},
+			[]image.Rectangle{top.Intersect(left)},
+			[]image.Rectangle{bottom.Intersect(right)},
+		},
+		{
+			"ribbon bottom right",
+			Badge{Text: "BETA", Shape: BadgeRibbon, Corner: BottomRight},
+			[]image.Rectangle{bottom.Intersect(right)},
+			[]image.Rectangle{top.Intersect(left)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			tt.badge.Color, tt.badge.TextColor = fill, text
+			got := tt.badge.Apply(src)
+			for _, r := range tt.in {
+				if count(got, r, fill) == 0 || count(got, r, text) == 0 {
+					st.Errorf("want badge and text in %v", r)
+				}
+			}
+			for _, r := range tt.out {
+				if n := count(got, r, fill); n > 0 {
+					st.Errorf("want no badge in %v, got %d pixels", r, n)
+				}
+			}
+		})
+	}
+	t.Run("source untouched", func(st *testing.T) {
+		Badge{Text: "DEV"}.Apply(src)
+		if n := count(src, src.Bounds(), color.NRGBA{0xff, 0xff, 0xff, 0xff}); n != 256*256 {
+			st.Errorf("source modified")
+		}
+	})
+	t.Run("long text fits", func(st *testing.T) {
+		for _, shape := range []BadgeShape{BadgeCorner, BadgeRibbon} {
+			got := Badge{Text: "A VERY LONG CHANNEL NAME", Shape: shape, Color: fill}.Apply(src)
+			if n := count(got, bottom.Intersect(left), fill); n > 0 {
+				st.Errorf("shape %d: want badge within the top right, got %d pixels elsewhere", shape, n)
+			}
+		}
+	})
+	t.Run("encoder", func(st *testing.T) {
+		iconset, err := NewEncoder(nil).WithBadge(Badge{Text: "BETA", Color: fill}).IconSet(src)
+		if err != nil {
+			st.Fatalf("unexpected error: %v", err)
+		}
+		for _, icon := range iconset.Icons {
+			if icon == nil {
+				continue
+			}
+			if c := color.NRGBAModel.Convert(icon.Image.At(0, 0)); c != (color.NRGBA{0xff, 0xff, 0xff, 0xff}) {
+				st.Errorf("%s: want untouched corner, got %v", icon.Type.ID, c)
+			}
+		}
+	})
+}
+
+// brokenFilter is a filter that fails, like a Badge whose label can't be
+// rendered.
+type brokenFilter struct{ err error }
+
+func (f brokenFilter) Apply(img image.Image) image.Image { return img }
+
+func (f brokenFilter) apply(image.Image) (image.Image, error) { return nil, f.err }
+
+func TestFailingFilter(t *testing.T) {
+	t.Parallel()
+	want := errors.New("no font")
+	_, err := NewEncoder(nil).WithFilters(brokenFilter{err: want}).IconSet(opaqueImage(64))
+	if !errors.Is(err, want) {
+		t.Errorf("want %v, got %v", want, err)
+	}
+}
@@ -0,0 +1,45 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+func benchSource() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 1024, 1024))
+	for y := 0; y < 1024; y++ {
+		for x := 0; x < 1024; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 0xff})
+		}
+	}
+	return img
+}
+
+func BenchmarkIconSet(b *testing.B) {
+	img := benchSource()
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		for ii := 0; ii < b.N; ii++ {
+			iconset := legacyIconSet(img, resize.MitchellNetravali)
+			if _, err := iconset.WriteTo(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("current", func(b *testing.B) {
+		b.ReportAllocs()
+		for ii := 0; ii < b.N; ii++ {
+			iconset, err := NewIconSet(img, MitchellNetravali)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := iconset.WriteTo(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -17,5 +17,32 @@ The pipes will be detected automatically, and both --input and --output will be
 
 		cat icon.png | icnsify > icon.icns
 
+Iconset directories can replace iconutil, in either direction.
+
+		icnsify -i AppIcon.iconset -o AppIcon.icns
+		icnsify -i AppIcon.icns -o AppIcon.iconset
+
+Windows icons are written for a .ico output, and read like any other image,
+as is the icon of an executable or dll.
+
+		icnsify -i icon.png -o icon.ico
+		icnsify -i icon.ico -o icon.icns
+		icnsify -i app.exe -o app.icns
+
+Go resource objects embed the icon into Windows binaries, one per --arch, written
+to the --output directory as rsrc_windows_<arch>.syso.
+
+		icnsify syso -i icon.png -o cmd/app
+
+Linux icons are exported as a hicolor icon theme tree with a skeleton .desktop
+entry, under the share directory of the --output prefix. An svg input is also
+kept as the scalable icon.
+
+		icnsify export --target linux --name myapp -i icon.svg -o dist/usr
+
+Placeholder icons can be generated from initials or short text.
+
+		icnsify generate --text QA --bg "#3366ff"
+
 `)
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/jackmordaunt/icns/svg"
+	"github.com/jackmordaunt/icns/v3"
+	"github.com/jackmordaunt/icns/v3/freedesktop"
+	"github.com/spf13/afero"
+)
+
+// exportLinux writes the icon name as a hicolor icon theme tree, with a
+// skeleton .desktop entry, into the prefix dir. An svg source is also kept as
+// the scalable icon, unless the pipeline changes the artwork.
+func exportLinux(dir, name string, data []byte, source *icns.Loaded, vector *svg.Source, pipeline *icns.Encoder) error {
+	var (
+		exporter = freedesktop.NewExporter(name).
+				WithPipeline(pipeline).
+				WithDesktop(freedesktop.DesktopEntry{})
+		files []freedesktop.File
+		err   error
+	)
+	if vector != nil {
+		if freedesktop.ScalableMatches(pipeline) {
+			exporter.WithScalable(data)
+		} else {
+			log.Printf("leaving out the scalable icon, which would not match the processed icons")
+		}
+		files, err = exporter.ExportSource(vector)
+	} else {
+		files, err = exporter.Export(source.Image)
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Path)
+		if err := fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := afero.WriteFile(fs, path, f.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackmordaunt/icns/v3"
+	"github.com/jackmordaunt/icns/v3/placeholder"
+	"github.com/spf13/pflag"
+)
+
+// generate writes a placeholder icns of text on a rounded square.
+func generate(args []string) {
+	flags := pflag.NewFlagSet("generate", pflag.ExitOnError)
+	var (
+		text = flags.StringP(
+			"text",
+			"t",
+			"",
+			"Initials or short text to draw on the icon.",
+		)
+		bg = flags.String(
+			"bg",
+			"#808080",
+			"Hex colour of the background.",
+		)
+		fg = flags.String(
+			"fg",
+			"",
+			"Hex colour of the text. Defaults to black or white, whichever contrasts more with the background.",
+		)
+		outputPath = flags.StringP(
+			"output",
+			"o",
+			"",
+			"Output path, defaults to <text>.icns.",
+		)
+	)
+	if err := flags.Parse(args); err != nil {
+		log.Fatalf("parsing flags: %v", err)
+	}
+	if *text == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	background, err := parseColor(*bg)
+	if err != nil {
+		log.Fatalf("parsing bg: %v", err)
+	}
+	icon := placeholder.Icon{Text: *text, Background: background}
+	if *fg != "" {
+		foreground, err := parseColor(*fg)
+		if err != nil {
+			log.Fatalf("parsing fg: %v", err)
+		}
+		icon.Foreground = foreground
+	}
+	out := *outputPath
+	if out == "" {
+		out = strings.ToLower(strings.Join(strings.Fields(*text), "-")) + ".icns"
+	}
+	if filepath.Ext(out) == "" {
+		out += ".icns"
+	}
+	if err := fs.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		log.Fatalf("preparing output directory: %v", err)
+	}
+	outputf, err := fs.Create(out)
+	if err != nil {
+		log.Fatalf("creating icns file: %v", err)
+	}
+	defer outputf.Close()
+	if err := icns.NewEncoder(outputf).EncodeSource(icon); err != nil {
+		log.Fatalf("encoding icns: %v", err)
+	}
+}
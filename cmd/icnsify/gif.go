@@ -0,0 +1,177 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"sort"
+)
+
+// encodeGIF writes m as a gif with a palette built from its colours: exact
+// when there are at most 256, otherwise reduced by median cut and dithered.
+// Gif transparency is all or nothing, so pixels under half opacity become
+// transparent and the rest opaque.
+func encodeGIF(w io.Writer, m image.Image) error {
+	var (
+		b   = m.Bounds()
+		src = image.NewNRGBA(b.Sub(b.Min))
+	)
+	draw.Draw(src, src.Rect, m, b.Min, draw.Src)
+	counts := map[color.NRGBA]int{}
+	for ii := 0; ii < len(src.Pix); ii += 4 {
+		p := src.Pix[ii : ii+4]
+		if p[3] < 0x80 {
+			p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+		} else {
+			p[3] = 0xff
+		}
+		counts[color.NRGBA{R: p[0], G: p[1], B: p[2], A: p[3]}]++
+	}
+	var (
+		palette = gifPalette(counts)
+		dst     = image.NewPaletted(src.Rect, palette)
+		drawer  = draw.Drawer(draw.Src)
+	)
+	if len(palette) < len(counts) {
+		drawer = draw.FloydSteinberg
+	}
+	drawer.Draw(dst, dst.Rect, src, image.Point{})
+	return gif.Encode(w, dst, nil)
+}
+
+// gifPalette returns a palette of at most 256 colours for the given colour
+// counts, keeping the transparent colour if used.
+func gifPalette(counts map[color.NRGBA]int) color.Palette {
+	var (
+		palette color.Palette
+		opaque  []colorCount
+		size    = 256
+	)
+	for c, n := range counts {
+		if c.A == 0 {
+			palette = append(palette, color.NRGBA{})
+			size--
+			continue
+		}
+		opaque = append(opaque, colorCount{c, n})
+	}
+	// Map order is random: sort so the palette is deterministic.
+	sort.Slice(opaque, func(ii, jj int) bool {
+		a, b := opaque[ii].c, opaque[jj].c
+		return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+	})
+	if len(opaque) <= size {
+		for _, cc := range opaque {
+			palette = append(palette, cc.c)
+		}
+		return palette
+	}
+	for _, box := range medianCut(opaque, size) {
+		palette = append(palette, box.mean())
+	}
+	return palette
+}
+
+type colorCount struct {
+	c color.NRGBA
+	n int
+}
+
+// colorBox is a set of colours, split along its widest channel.
+type colorBox []colorCount
+
+// medianCut splits colours into at most n boxes, repeatedly halving the box
+// with the widest channel at its weighted median.
+func medianCut(colors []colorCount, n int) []colorBox {
+	boxes := []colorBox{colors}
+	for len(boxes) < n {
+		var (
+			widest  = -1
+			channel int
+			width   uint8
+		)
+		for ii, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, w := box.widest(); widest < 0 || w > width {
+				widest, channel, width = ii, ch, w
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		box := boxes[widest]
+		sort.SliceStable(box, func(ii, jj int) bool {
+			return channelOf(box[ii].c, channel) < channelOf(box[jj].c, channel)
+		})
+		var total, half int
+		for _, cc := range box {
+			total += cc.n
+		}
+		split := 1
+		for ii, cc := range box[:len(box)-1] {
+			if half += cc.n; half*2 >= total {
+				split = ii + 1
+				break
+			}
+		}
+		boxes[widest] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+	return boxes
+}
+
+// widest returns the channel with the largest range in the box, and the
+// range.
+func (box colorBox) widest() (int, uint8) {
+	var (
+		channel int
+		width   uint8
+	)
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := uint8(0xff), uint8(0)
+		for _, cc := range box {
+			v := channelOf(cc.c, ch)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > width || ch == 0 {
+			channel, width = ch, hi-lo
+		}
+	}
+	return channel, width
+}
+
+// mean returns the average colour of the box, weighted by count.
+func (box colorBox) mean() color.NRGBA {
+	var r, g, b, total int
+	for _, cc := range box {
+		r += int(cc.c.R) * cc.n
+		g += int(cc.c.G) * cc.n
+		b += int(cc.c.B) * cc.n
+		total += cc.n
+	}
+	return color.NRGBA{
+		R: uint8((r + total/2) / total),
+		G: uint8((g + total/2) / total),
+		B: uint8((b + total/2) / total),
+		A: 0xff,
+	}
+}
+
+func channelOf(c color.NRGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	}
+	return c.B
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestEncodeGIF(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc string
+		// colour of the pixel at x, y.
+		colour func(x, y int) color.NRGBA
+		// tolerance per channel of the decoded colours.
+		tolerance int
+	}{
+		{
+			"few colours kept exactly",
+			func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), B: 0x80, A: 0xff}
+			},
+			0,
+		},
+		{
+			"alpha thresholded",
+			func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 16), G: 0x40, B: uint8(y * 16), A: uint8(x * 16)}
+			},
+			0,
+		},
+		{
+			"many colours reduced",
+			func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8((x + y) * 2), A: 0xff}
+			},
+			48,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			size := 16
+			if tt.tolerance > 0 {
+				size = 64
+			}
+			src := image.NewNRGBA(image.Rect(0, 0, size, size))
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					src.SetNRGBA(x, y, tt.colour(x, y))
+				}
+			}
+			buf := bytes.NewBuffer(nil)
+			if err := encodeGIF(buf, src); err != nil {
+				st.Fatalf("encoding: %v", err)
+			}
+			got, err := gif.Decode(buf)
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					var (
+						want = tt.colour(x, y)
+						have = color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+					)
+					if want.A < 0x80 {
+						if have.A != 0 {
+							st.Fatalf("pixel (%d, %d): want transparent, got %v", x, y, have)
+						}
+						continue
+					}
+					want.A = 0xff
+					if !near(want, have, tt.tolerance) {
+						st.Fatalf("pixel (%d, %d): want %v, got %v", x, y, want, have)
+					}
+				}
+			}
+		})
+	}
+}
+
+func near(a, b color.NRGBA, tolerance int) bool {
+	for _, d := range []int{
+		int(a.R) - int(b.R),
+		int(a.G) - int(b.G),
+		int(a.B) - int(b.B),
+		int(a.A) - int(b.A),
+	} {
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
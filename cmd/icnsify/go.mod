@@ -3,12 +3,23 @@ module github.com/jackmordaunt/icns/cmd/icnsify
 go 1.21.5
 
 require (
+	github.com/jackmordaunt/icns/svg v0.0.0-00010101000000-000000000000
 	github.com/jackmordaunt/icns/v3 v3.0.0
 	github.com/spf13/afero v1.11.0
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/image v0.14.0
 )
 
 require (
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c // indirect
+	github.com/srwiley/rasterx v0.0.0-20210519020934-456a8d69b780 // indirect
+	golang.org/x/net v0.19.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
+
+// Until a v3 release with the encoder, ico, winres and freedesktop APIs is
+// tagged and required.
+replace github.com/jackmordaunt/icns/v3 => ../..
+
+replace github.com/jackmordaunt/icns/svg => ../../svg
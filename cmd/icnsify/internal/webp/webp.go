@@ -0,0 +1,466 @@
+// Package webp encodes lossless WebP images.
+package webp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// Encode writes m as a lossless (VP8L) WebP.
+// Pixels go through the subtract green and predictor transforms, then are
+// stored as literals under a single set of prefix codes, without the colour
+// cache and backward references of a full encoder. Files are larger than
+// those of libwebp, but simple to produce and readable everywhere.
+func Encode(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > 1<<14 || b.Dy() > 1<<14 {
+		return fmt.Errorf("webp: cannot encode %dx%d image", b.Dx(), b.Dy())
+	}
+	var (
+		width = b.Dx()
+		pix   = make([]uint8, 0, 4*b.Dx()*b.Dy())
+		alpha bool
+	)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			pix = append(pix, c.R-c.G, c.G, c.B-c.G, c.A)
+			alpha = alpha || c.A != 0xff
+		}
+	}
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(b.Dx()-1), 14)
+	bw.write(uint32(b.Dy()-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+	// Transforms are listed in the order they are applied.
+	bw.write(1, 1)
+	bw.write(webpSubtractGreen, 2)
+	bw.write(1, 1)
+	bw.write(webpPredictor, 2)
+	bw.write(webpTileBits-2, 3)
+	residuals, modes := predict(pix, width)
+	writeEntropyImage(bw, modes, false)
+	bw.write(0, 1)
+	writeEntropyImage(bw, residuals, true)
+	data := bw.bytes()
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(4+8+len(data)+len(data)%2))
+	buf.WriteString("WEBPVP8L")
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// VP8L transform types.
+const (
+	webpPredictor     = 0
+	webpSubtractGreen = 2
+)
+
+// webpTileBits is the log2 side of the tiles that share a predictor.
+const webpTileBits = 4
+
+// predict returns the residuals of pix, rgba samples of the given width,
+// against the predictor that suits each tile best, and the tile modes as an
+// image with the mode in the green channel.
+func predict(pix []uint8, width int) (residuals, modes []uint8) {
+	var (
+		height = len(pix) / 4 / width
+		tiles  = (width + 1<<webpTileBits - 1) >> webpTileBits
+		rows   = (height + 1<<webpTileBits - 1) >> webpTileBits
+	)
+	residuals = make([]uint8, len(pix))
+	modes = make([]uint8, 4*tiles*rows)
+	// Pick the mode with the smallest residuals over each tile.
+	for ty := 0; ty < rows; ty++ {
+		for tx := 0; tx < tiles; tx++ {
+			var best, bestCost = 0, -1
+			for mode := 0; mode < 14; mode++ {
+				var cost int
+				for y := ty << webpTileBits; y < height && y < (ty+1)<<webpTileBits; y++ {
+					for x := tx << webpTileBits; x < width && x < (tx+1)<<webpTileBits; x++ {
+						p := 4 * (y*width + x)
+						pred := predictPixel(pix, p, p-4*width, x, y, mode)
+						for ii := 0; ii < 4; ii++ {
+							d := int(int8(pix[p+ii] - pred[ii]))
+							if d < 0 {
+								d = -d
+							}
+							cost += d
+						}
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[4*(ty*tiles+tx)+1] = uint8(best)
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var (
+				p    = 4 * (y*width + x)
+				mode = int(modes[4*((y>>webpTileBits)*tiles+x>>webpTileBits)+1])
+				pred = predictPixel(pix, p, p-4*width, x, y, mode)
+			)
+			for ii := 0; ii < 4; ii++ {
+				residuals[p+ii] = pix[p+ii] - pred[ii]
+			}
+		}
+	}
+	return residuals, modes
+}
+
+// predictPixel predicts the pixel at offset p from its decoded neighbours,
+// where top is the offset of the pixel above. Addressing follows the decoder
+// exactly, so the top right of the last column is the first pixel of the
+// current row.
+func predictPixel(pix []uint8, p, top, x, y, mode int) [4]uint8 {
+	var (
+		at = func(q int) [4]uint8 {
+			return [4]uint8{pix[q], pix[q+1], pix[q+2], pix[q+3]}
+		}
+		each = func(f func(ii int) uint8) (c [4]uint8) {
+			for ii := range c {
+				c[ii] = f(ii)
+			}
+			return c
+		}
+	)
+	switch {
+	case x == 0 && y == 0:
+		return [4]uint8{3: 0xff}
+	case y == 0:
+		return at(p - 4)
+	case x == 0:
+		return at(top)
+	}
+	l, t, tl, tr := at(p-4), at(top), at(top-4), at(top+4)
+	switch mode {
+	case 0:
+		return [4]uint8{3: 0xff}
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return each(func(ii int) uint8 { return avg2(avg2(l[ii], tr[ii]), t[ii]) })
+	case 6:
+		return each(func(ii int) uint8 { return avg2(l[ii], tl[ii]) })
+	case 7:
+		return each(func(ii int) uint8 { return avg2(l[ii], t[ii]) })
+	case 8:
+		return each(func(ii int) uint8 { return avg2(tl[ii], t[ii]) })
+	case 9:
+		return each(func(ii int) uint8 { return avg2(t[ii], tr[ii]) })
+	case 10:
+		return each(func(ii int) uint8 { return avg2(avg2(l[ii], tl[ii]), avg2(t[ii], tr[ii])) })
+	case 11:
+		// Select whichever of L and T is nearer to L + T - TL.
+		var dl, dt int
+		for ii := range l {
+			dl += absDiff(tl[ii], t[ii])
+			dt += absDiff(tl[ii], l[ii])
+		}
+		if dl < dt {
+			return l
+		}
+		return t
+	case 12:
+		return each(func(ii int) uint8 { return clampByte(int(l[ii]) + int(t[ii]) - int(tl[ii])) })
+	default:
+		return each(func(ii int) uint8 {
+			a := int(avg2(l[ii], t[ii]))
+			return clampByte(a + (a-int(tl[ii]))/2)
+		})
+	}
+}
+
+func avg2(a, b uint8) uint8 {
+	return uint8((int(a) + int(b)) / 2)
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func clampByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 0xff {
+		return 0xff
+	}
+	return uint8(v)
+}
+
+// writeEntropyImage writes rgba samples under a single set of prefix codes.
+// Only the top level image signals the absence of meta prefix codes.
+func writeEntropyImage(bw *bitWriter, pix []uint8, topLevel bool) {
+	// Green shares its alphabet with backward reference lengths, which are
+	// never used here.
+	freq := [5][]int{make([]int, 280), make([]int, 256), make([]int, 256), make([]int, 256), make([]int, 40)}
+	for p := 0; p < len(pix); p += 4 {
+		freq[0][pix[p+1]]++
+		freq[1][pix[p]]++
+		freq[2][pix[p+2]]++
+		freq[3][pix[p+3]]++
+	}
+	// No colour cache.
+	bw.write(0, 1)
+	if topLevel {
+		bw.write(0, 1)
+	}
+	var codes [5]prefixCode
+	for ii := range codes {
+		codes[ii] = writePrefixCode(bw, freq[ii])
+	}
+	for p := 0; p < len(pix); p += 4 {
+		codes[0].write(bw, int(pix[p+1]))
+		codes[1].write(bw, int(pix[p]))
+		codes[2].write(bw, int(pix[p+2]))
+		codes[3].write(bw, int(pix[p+3]))
+	}
+}
+
+// bitWriter packs values least significant bit first.
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	bits uint
+}
+
+func (bw *bitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.bits
+	bw.bits += n
+	for bw.bits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.bits -= 8
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.bits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.bits = 0, 0
+	}
+	return bw.buf
+}
+
+// prefixCode is a canonical Huffman code, with codes bit reversed for
+// writing least significant bit first.
+type prefixCode struct {
+	codes   []uint32
+	lengths []uint8
+}
+
+func (p prefixCode) write(bw *bitWriter, symbol int) {
+	bw.write(p.codes[symbol], uint(p.lengths[symbol]))
+}
+
+// codeLengthOrder is the order in which code length code lengths are stored.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writePrefixCode writes the prefix code for symbols with the given
+// frequencies, and returns it.
+func writePrefixCode(bw *bitWriter, freq []int) prefixCode {
+	var used []int
+	for symbol, f := range freq {
+		if f > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) <= 1 {
+		// A simple code of one symbol takes no bits per symbol.
+		symbol := 0
+		if len(used) == 1 {
+			symbol = used[0]
+		}
+		bw.write(1, 1)
+		bw.write(0, 1)
+		if symbol < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbol), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbol), 8)
+		}
+		return prefixCode{codes: make([]uint32, len(freq)), lengths: make([]uint8, len(freq))}
+	}
+	lengths := huffmanLengths(freq, 15)
+	// Run length encode the code lengths, using 17 and 18 for runs of zeros.
+	type token struct {
+		symbol int
+		extra  uint32
+		bits   uint
+	}
+	var tokens []token
+	for ii := 0; ii < len(lengths); {
+		if lengths[ii] != 0 {
+			tokens = append(tokens, token{symbol: int(lengths[ii])})
+			ii++
+			continue
+		}
+		run := 0
+		for ii+run < len(lengths) && lengths[ii+run] == 0 {
+			run++
+		}
+		ii += run
+		for run >= 11 {
+			n := run
+			if n > 138 {
+				n = 138
+			}
+			tokens = append(tokens, token{symbol: 18, extra: uint32(n - 11), bits: 7})
+			run -= n
+		}
+		if run >= 3 {
+			tokens = append(tokens, token{symbol: 17, extra: uint32(run - 3), bits: 3})
+			run = 0
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, token{symbol: 0})
+		}
+	}
+	clFreq := make([]int, 19)
+	for _, t := range tokens {
+		clFreq[t.symbol]++
+	}
+	clLengths := huffmanLengths(clFreq, 7)
+	count := 4
+	for ii, symbol := range codeLengthOrder {
+		if clLengths[symbol] != 0 && ii+1 > count {
+			count = ii + 1
+		}
+	}
+	bw.write(0, 1)
+	bw.write(uint32(count-4), 4)
+	for _, symbol := range codeLengthOrder[:count] {
+		bw.write(uint32(clLengths[symbol]), 3)
+	}
+	// Code lengths follow for the whole alphabet.
+	bw.write(0, 1)
+	cl := canonical(clLengths)
+	for _, t := range tokens {
+		cl.write(bw, t.symbol)
+		bw.write(t.extra, t.bits)
+	}
+	return canonical(lengths)
+}
+
+// canonical assigns canonical codes to lengths. A code with a single symbol
+// takes no bits.
+func canonical(lengths []uint8) prefixCode {
+	var (
+		p     = prefixCode{codes: make([]uint32, len(lengths)), lengths: make([]uint8, len(lengths))}
+		count [16]uint32
+		used  int
+	)
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+			used++
+		}
+	}
+	if used == 1 {
+		return p
+	}
+	var (
+		next [16]uint32
+		code uint32
+	)
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		var reversed uint32
+		for ii := uint8(0); ii < l; ii++ {
+			reversed = reversed<<1 | (c>>ii)&1
+		}
+		p.codes[symbol], p.lengths[symbol] = reversed, l
+	}
+	return p
+}
+
+// huffmanLengths returns Huffman code lengths for freq, no longer than
+// limit. Frequencies are flattened until the code fits.
+func huffmanLengths(freq []int, limit uint8) []uint8 {
+	f := append([]int(nil), freq...)
+	for {
+		type node struct {
+			weight  int
+			symbols []int
+		}
+		var (
+			nodes   []node
+			lengths = make([]uint8, len(f))
+		)
+		for symbol, w := range f {
+			if w > 0 {
+				nodes = append(nodes, node{weight: w, symbols: []int{symbol}})
+			}
+		}
+		if len(nodes) == 1 {
+			lengths[nodes[0].symbols[0]] = 1
+			return lengths
+		}
+		for len(nodes) > 1 {
+			sort.SliceStable(nodes, func(ii, jj int) bool {
+				return nodes[ii].weight < nodes[jj].weight
+			})
+			merged := node{
+				weight:  nodes[0].weight + nodes[1].weight,
+				symbols: append(append([]int(nil), nodes[0].symbols...), nodes[1].symbols...),
+			}
+			for _, symbol := range merged.symbols {
+				lengths[symbol]++
+			}
+			nodes = append(nodes[2:], merged)
+		}
+		var max uint8
+		for _, l := range lengths {
+			if l > max {
+				max = l
+			}
+		}
+		if max <= limit {
+			return lengths
+		}
+		for symbol, w := range f {
+			if w > 0 {
+				f[symbol] = w/2 + 1
+			}
+		}
+	}
+}
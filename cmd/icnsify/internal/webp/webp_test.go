@@ -0,0 +1,122 @@
+package webp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewSource(1))
+	fill := func(w, h int, f func(x, y int) color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.SetNRGBA(x, y, f(x, y))
+			}
+		}
+		return img
+	}
+	tests := []struct {
+		desc string
+		img  image.Image
+	}{
+		{"opaque", fill(64, 64, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: 0x80, A: 0xff}
+		})},
+		{"alpha", fill(64, 64, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: 0x20, G: uint8(y * 4), B: uint8(x), A: uint8(x + y)}
+		})},
+		{"transparent colours kept", fill(8, 8, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 30), G: uint8(y * 30), B: 0x55, A: 0}
+		})},
+		{"many colours", fill(128, 96, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: uint8(rng.Intn(256))}
+		})},
+		{"single colour", fill(32, 32, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+		})},
+		{"1x1", fill(1, 1, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: 0xfe, G: 0x01, B: 0x7f, A: 0x80}
+		})},
+		{"odd size", fill(17, 33, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * y), G: uint8(x ^ y), B: uint8(x + 3*y), A: uint8(0xff - x)}
+		})},
+		{"wide", fill(300, 3, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x), G: uint8(y * 100), B: uint8(x / 2), A: 0xff}
+		})},
+		{"offset 16 bit", func() image.Image {
+			img := image.NewNRGBA64(image.Rect(5, 7, 24, 20))
+			for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+				for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+					img.SetNRGBA64(x, y, color.NRGBA64{R: uint16(x) << 11, G: uint16(y) << 11, B: 0x8080, A: 0xffff})
+				}
+			}
+			return img
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			if err := Encode(buf, tt.img); err != nil {
+				st.Fatalf("encoding: %v", err)
+			}
+			got, err := xwebp.Decode(buf)
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			var (
+				b  = tt.img.Bounds()
+				gb = got.Bounds()
+			)
+			if gb.Dx() != b.Dx() || gb.Dy() != b.Dy() {
+				st.Fatalf("want %dx%d, got %dx%d", b.Dx(), b.Dy(), gb.Dx(), gb.Dy())
+			}
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					var (
+						want = color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y))
+						have = color.NRGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y))
+					)
+					if have != want {
+						st.Fatalf("pixel (%d, %d): want %v, got %v", x, y, want, have)
+					}
+				}
+			}
+		})
+	}
+}
+
+func FuzzEncode(f *testing.F) {
+	f.Add(uint8(4), []byte{0x12, 0x34, 0x56, 0xff, 0xfe, 0x01, 0x7f, 0x80})
+	f.Fuzz(func(t *testing.T, width uint8, pix []byte) {
+		if width == 0 || len(pix) < 4*int(width) {
+			return
+		}
+		var (
+			w   = int(width)
+			h   = len(pix) / 4 / w
+			img = &image.NRGBA{Pix: pix[:4*w*h], Stride: 4 * w, Rect: image.Rect(0, 0, w, h)}
+			buf = bytes.NewBuffer(nil)
+		)
+		if err := Encode(buf, img); err != nil {
+			t.Fatalf("encoding: %v", err)
+		}
+		got, err := xwebp.Decode(buf)
+		if err != nil {
+			t.Fatalf("decoding: %v", err)
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if have, want := color.NRGBAModel.Convert(got.At(x, y)), img.At(x, y); have != want {
+					t.Fatalf("pixel (%d, %d): want %v, got %v", x, y, want, have)
+				}
+			}
+		}
+	})
+}
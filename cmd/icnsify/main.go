@@ -1,17 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/jackmordaunt/icns/cmd/icnsify/internal/webp"
+	"github.com/jackmordaunt/icns/svg"
 	"github.com/jackmordaunt/icns/v3"
+	"github.com/jackmordaunt/icns/v3/ico"
+	"github.com/jackmordaunt/icns/v3/winres"
 	"github.com/spf13/afero"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	// Register the remaining decoders for image.Decode.
+	_ "golang.org/x/image/webp"
 
 	"github.com/spf13/pflag"
 )
@@ -24,82 +39,488 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		generate(os.Args[2:])
+		return
+	}
+	// The syso and export subcommands take the same flags, writing resource
+	// objects or an icon theme tree instead of an icns file.
+	var mode string
+	if len(os.Args) > 1 && (os.Args[1] == "syso" || os.Args[1] == "export") {
+		mode = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
 	var (
 		inputPath = pflag.StringP(
 			"input",
 			"i",
 			"",
-			"Input image for conversion to icns from jpg|png or visa versa.",
+			"Input image for conversion to icns from png|jpg|gif|bmp|tiff|webp|svg|ico, the icon of an exe|dll, or an .iconset directory, or visa versa.",
 		)
 		outputPath = pflag.StringP(
 			"output",
 			"o",
 			"",
-			"Output path, defaults to <path/to/image>.(icns|png) depending on input.",
+			"Output path, defaults to <path/to/image>.(icns|png) depending on input. A path ending in .iconset writes an iconset directory, and .ico a Windows icon.",
 		)
-		resize = pflag.IntP(
+		resize = pflag.StringP(
 			"resize",
 			"r",
-			5,
-			"Quality of resize algorithm. Values range from 0 to 5, fastest to slowest execution time. Defaults to slowest for best quality.",
+			"5",
+			"Quality of resize algorithm. Values range from 0 to 5, fastest to slowest execution time. Defaults to slowest for best quality. "+
+				"Also accepts catmullrom, approxbilinear, lanczos, and for pixel art sources pixel or pixel-scale2x.",
+		)
+		sharpen = pflag.Float64Slice(
+			"sharpen",
+			nil,
+			"Sharpen downscaled icons with an unsharp mask given as radius,amount[,threshold], e.g. 0.8,0.6,2. Strength increases for smaller sizes.",
+		)
+		optimize = pflag.Bool(
+			"optimize",
+			false,
+			"Losslessly optimise every icon for size. Much slower.",
+		)
+		budget = pflag.Int(
+			"max-size",
+			0,
+			"Exit non-zero if the icns is larger than this many bytes, listing the icons to drop. The icns is still written.",
+		)
+		depth = pflag.String(
+			"depth",
+			"auto",
+			"Bits per channel of the icons: 8, 16 to preserve 16 bit sources, or auto to keep 16 bits only if the source uses them.",
+		)
+		colorSpace = pflag.String(
+			"color-space",
+			"none",
+			"Colour space of the icons: srgb or p3 to convert from the source's embedded ICC profile and tag the icons, or none to leave colours untouched.",
+		)
+		trim = pflag.String(
+			"trim",
+			"",
+			"Trim transparent borders and recentre the artwork, leaving a margin given as a percentage of the icon, or safe for the macOS icon grid.",
+		)
+		style = pflag.String(
+			"style",
+			"",
+			"Composite the artwork into a platform icon template at every size. Only macos is supported.",
+		)
+		background = pflag.String(
+			"background",
+			"",
+			"Hex colour, e.g. #1e90ff, filling the shape behind the artwork when a style is used.",
+		)
+		hueShift = pflag.Float64(
+			"hue-shift",
+			0,
+			"Rotate the hue of the icon by this many degrees.",
+		)
+		tint = pflag.String(
+			"tint",
+			"",
+			"Hex colour to recolour the icon with, keeping its lightness.",
+		)
+		tintAmount = pflag.Float64(
+			"tint-amount",
+			1,
+			"Strength of the tint, from 0 to 1.",
+		)
+		grayscale = pflag.Float64(
+			"grayscale",
+			0,
+			"Desaturate the icon, e.g. for a disabled state, by an amount from 0 to 1.",
+		)
+		silhouette = pflag.String(
+			"template",
+			"",
+			"Turn the icon into a silhouette of this hex colour, keeping only its alpha.",
+		)
+		badge = pflag.String(
+			"badge",
+			"",
+			"Overlay a label, such as BETA, on a corner of the icon.",
+		)
+		badgeShape = pflag.String(
+			"badge-shape",
+			"corner",
+			"Shape of the badge: corner for a pill or ribbon for a diagonal band.",
+		)
+		badgeCorner = pflag.String(
+			"badge-corner",
+			"top-right",
+			"Corner of the badge: top-right, top-left, bottom-right or bottom-left.",
+		)
+		badgeColor = pflag.String(
+			"badge-color",
+			"#e53935",
+			"Hex colour of the badge.",
+		)
+		badgeTextColor = pflag.String(
+			"badge-text-color",
+			"#ffffff",
+			"Hex colour of the badge text.",
+		)
+		arches = pflag.StringSlice(
+			"arch",
+			winres.Arches,
+			"Architectures to write resource objects for with syso, any of amd64, 386 and arm64.",
+		)
+		platform = pflag.String(
+			"target",
+			"linux",
+			"Platform to export icons for with export. Only linux is supported, writing a hicolor icon theme tree and a .desktop entry.",
+		)
+		name = pflag.String(
+			"name",
+			"",
+			"Name of the exported icon and .desktop entry, defaults to the name of the input.",
+		)
+		verbose = pflag.BoolP(
+			"verbose",
+			"v",
+			false,
+			"Log the size of each icon and the time taken to stderr.",
 		)
 	)
+	pflag.Lookup("trim").NoOptDefVal = "safe"
+	pflag.Lookup("grayscale").NoOptDefVal = "1"
+	pflag.Lookup("template").NoOptDefVal = "#000000"
 	pflag.Parse()
-	in, out, algorithm := sanitiseInputs(*inputPath, *outputPath, *resize)
+	target := *outputPath
+	if mode != "" {
+		// The output is a directory, not a file.
+		target = ""
+	}
+	in, out, algorithm, err := sanitiseInputs(*inputPath, target, *resize)
+	if err != nil {
+		log.Fatalf("parsing inputs: %v", err)
+	}
+	if mode != "" {
+		out = filepath.Clean(*outputPath)
+		if isIconset(in) {
+			log.Fatalf("parsing inputs: %s needs an image, icns or ico input", mode)
+		}
+	}
+	if mode == "syso" {
+		if err := checkArches(*arches); err != nil {
+			log.Fatalf("parsing arch: %v", err)
+		}
+	}
+	if mode == "export" {
+		if *platform != "linux" {
+			log.Fatalf("unknown target %q, want linux", *platform)
+		}
+		if *name == "" && in != "" {
+			*name = strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))
+		}
+		if *name == "" {
+			log.Fatalf("parsing inputs: export needs a --name when piping")
+		}
+	}
+	bitDepth, ok := bitDepths[*depth]
+	if !ok {
+		log.Fatalf("unknown depth %q, want 8, 16 or auto", *depth)
+	}
+	space, ok := colorSpaces[strings.ToLower(*colorSpace)]
+	if !ok {
+		log.Fatalf("unknown color space %q, want none, srgb or p3", *colorSpace)
+	}
+	mask, err := parseSharpen(*sharpen)
+	if err != nil {
+		log.Fatalf("parsing sharpen: %v", err)
+	}
+	margin, err := parseTrim(*trim)
+	if err != nil {
+		log.Fatalf("parsing trim: %v", err)
+	}
+	template, err := parseStyle(*style, *background)
+	if err != nil {
+		log.Fatalf("parsing style: %v", err)
+	}
+	filters, err := parseTransforms(*hueShift, *tint, *tintAmount, *grayscale, *silhouette)
+	if err != nil {
+		log.Fatalf("parsing colour transforms: %v", err)
+	}
+	label, err := parseBadge(*badge, *badgeShape, *badgeCorner, *badgeColor, *badgeTextColor)
+	if err != nil {
+		log.Fatalf("parsing badge: %v", err)
+	}
 	if !piping {
 		if in == "" {
 			usage()
 			os.Exit(0)
 		}
+		if isIconset(in) {
+			if err := packIconset(in, out); err != nil {
+				log.Fatalf("converting iconset: %v", err)
+			}
+			return
+		}
 		sourcef, err := fs.Open(in)
 		if err != nil {
 			log.Fatalf("opening source image: %v", err)
 		}
 		defer sourcef.Close()
 		input = sourcef
-		if err := fs.MkdirAll(filepath.Dir(out), 0755); err != nil {
-			log.Fatalf("preparing output directory: %v", err)
+		if !isIconset(out) && mode == "" {
+			if err := fs.MkdirAll(filepath.Dir(out), 0755); err != nil {
+				log.Fatalf("preparing output directory: %v", err)
+			}
+			outputf, err := fs.Create(out)
+			if err != nil {
+				log.Fatalf("creating icns file: %v", err)
+			}
+			defer outputf.Close()
+			output = outputf
 		}
-		outputf, err := fs.Create(out)
-		if err != nil {
-			log.Fatalf("creating icns file: %v", err)
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		log.Fatalf("reading input: %v", err)
+	}
+	if isPE(in, data) {
+		if data, err = extractPE(data); err != nil {
+			log.Fatalf("reading executable icon: %v", err)
 		}
-		defer outputf.Close()
-		output = outputf
 	}
-	img, format, err := image.Decode(input)
+	var (
+		source = &icns.Loaded{}
+		vector *svg.Source
+	)
+	if isSVG(in, data) {
+		vector, err = svg.Parse(bytes.NewReader(data))
+	} else {
+		source, err = icns.Load(bytes.NewReader(data))
+	}
+	if errors.Is(err, image.ErrFormat) {
+		log.Fatalf("decoding input: unsupported format, want one of %s", decodeFormats)
+	}
 	if err != nil {
 		log.Fatalf("decoding input: %v", err)
 	}
-	if format == "icns" {
+	if source.Format == "icns" && isIconset(out) && !piping && mode == "" {
+		set, err := icns.NewDecoder(bytes.NewReader(data)).DecodeIconSet()
+		if err != nil {
+			log.Fatalf("decoding icns: %v", err)
+		}
+		if err := writeIconset(out, set); err != nil {
+			log.Fatalf("writing iconset: %v", err)
+		}
+	} else if source.Format == "icns" && mode == "" {
 		imageType := strings.ToLower(filepath.Ext(out))
 		if _, ok := encoders[imageType]; !ok {
 			imageType = ".png"
 		}
-		if err := encoders[imageType](output, img); err != nil {
+		if err := encoders[imageType](output, source.Image); err != nil {
 			log.Fatalf("encoding %s: %v", imageType, err)
 		}
 	} else {
-		enc := icns.NewEncoder(output).
-			WithAlgorithm(algorithm)
-		if err := enc.Encode(img); err != nil {
-			log.Fatalf("encoding icns: %v", err)
+		enc := icns.NewEncoder(output)
+		if p, ok := algorithm.(icns.PixelArt); ok {
+			enc.WithPixelArt(p.EdgeDirected)
+		} else {
+			enc.WithResampler(algorithm)
+		}
+		if mask != nil {
+			enc.WithSharpen(*mask)
+		}
+		if margin != nil {
+			enc.WithTrim(*margin)
+		}
+		enc.WithFilters(filters...)
+		if label != nil {
+			enc.WithBadge(*label)
+		}
+		if template != nil {
+			enc.WithStyle(*template)
 		}
+		if *verbose {
+			enc.WithObserver(logStats)
+		}
+		enc.WithOptimize(*optimize).
+			WithSizeBudget(*budget).
+			WithBitDepth(bitDepth).
+			WithColorSpace(space)
+		if space != icns.ColorSpaceNone {
+			if source.ProfileErr != nil {
+				log.Printf("ignoring embedded colour profile, assuming sRGB: %v", source.ProfileErr)
+			}
+			enc.WithSourceProfile(source.Profile)
+		}
+		if mode == "syso" {
+			err = writeSyso(out, *arches, data, source, vector, enc)
+		} else if mode == "export" {
+			err = exportLinux(out, *name, data, source, vector, enc)
+		} else if isICO(out) && !piping {
+			windows := ico.NewEncoder(output).WithPipeline(enc)
+			if vector != nil {
+				err = windows.EncodeSource(vector)
+			} else {
+				err = windows.Encode(source.Image)
+			}
+		} else if isIconset(out) && !piping {
+			var set *icns.IconSet
+			if set, err = iconSet(enc, data, source, vector); canWrite(err) {
+				if werr := writeIconset(out, set); werr != nil {
+					err = werr
+				}
+			}
+		} else {
+			var set *icns.IconSet
+			if set, err = iconSet(enc, data, source, vector); canWrite(err) {
+				if _, werr := set.WriteTo(output); werr != nil {
+					err = werr
+				}
+			}
+		}
+		if err != nil {
+			format := strings.TrimPrefix(filepath.Ext(out), ".")
+			switch mode {
+			case "syso":
+				format = "syso"
+			case "export":
+				format = *platform + " icons"
+			}
+			log.Fatalf("encoding %s: %v", format, err)
+		}
+	}
+}
+
+// canWrite reports whether the icon set should be written: when there is no
+// error, or when it is only over the size budget, so that the user can still
+// inspect the icns before dropping icons.
+func canWrite(err error) bool {
+	var budget icns.ErrSizeBudget
+	return err == nil || errors.As(err, &budget)
+}
+
+// iconSet creates the icns icons for the source. Every image of an ico is
+// considered, rather than just the largest.
+func iconSet(enc *icns.Encoder, data []byte, source *icns.Loaded, vector *svg.Source) (*icns.IconSet, error) {
+	switch {
+	case vector != nil:
+		return enc.IconSetSource(vector)
+	case source.Format == "ico":
+		return ico.IconSet(bytes.NewReader(data), enc)
 	}
+	return enc.IconSet(source.Image)
+}
+
+// isSVG reports whether the input is an SVG image, by its extension or, when
+// piping, by looking for an svg element near the start.
+func isSVG(path string, data []byte) bool {
+	if path != "" {
+		return strings.EqualFold(filepath.Ext(path), ".svg")
+	}
+	if len(data) > 1024 {
+		data = data[:1024]
+	}
+	return bytes.Contains(data, []byte("<svg"))
+}
+
+// isIconset reports whether path is an iconset directory, as used by
+// iconutil.
+func isIconset(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".iconset")
+}
+
+// isICO reports whether path is a Windows icon.
+func isICO(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ico")
+}
+
+// isPE reports whether the input is a Windows executable or dll, by its
+// signature, or a resource object by its extension.
+func isPE(path string, data []byte) bool {
+	return bytes.HasPrefix(data, []byte("MZ")) || strings.EqualFold(filepath.Ext(path), ".syso")
+}
+
+// extractPE returns the icon of a Windows binary with the largest image as an
+// ico, preferring the first in resource order on ties.
+func extractPE(data []byte) ([]byte, error) {
+	groups, err := winres.ReadPE(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		best    = groups[0]
+		largest int
+	)
+	for _, g := range groups {
+		for _, img := range g.Images {
+			if img.Size() > largest {
+				best, largest = g, img.Size()
+			}
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := best.WriteICO(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeIconset writes every icon in set to the iconset directory dir.
+func writeIconset(dir string, set *icns.IconSet) error {
+	files, err := icns.IconsetFiles(set)
+	if err != nil {
+		return err
+	}
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := afero.WriteFile(fs, filepath.Join(dir, f.Name), f.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packIconset converts the iconset directory in into the icns file out.
+func packIconset(in, out string) error {
+	set, err := icns.ReadIconset(os.DirFS(filepath.Dir(in)), filepath.Base(in))
+	if err != nil {
+		return err
+	}
+	if err := fs.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return fmt.Errorf("preparing output directory: %w", err)
+	}
+	f, err := fs.Create(out)
+	if err != nil {
+		return fmt.Errorf("creating icns file: %w", err)
+	}
+	defer f.Close()
+	if _, err := set.WriteTo(f); err != nil {
+		return fmt.Errorf("writing icns: %w", err)
+	}
+	return f.Close()
 }
 
 func sanitiseInputs(
 	inputPath string,
 	outputPath string,
-	resize int,
-) (string, string, icns.InterpolationFunction) {
-	if filepath.Ext(inputPath) == ".icns" {
+	resize string,
+) (string, string, icns.Resampler, error) {
+	if inputPath != "" {
+		inputPath = filepath.Clean(inputPath)
+	}
+	if outputPath != "" {
+		outputPath = filepath.Clean(outputPath)
+	}
+	if isIconset(inputPath) && isIconset(outputPath) {
+		return "", "", nil, fmt.Errorf("cannot convert an iconset to an iconset")
+	}
+	if filepath.Ext(inputPath) == ".icns" && !isIconset(outputPath) {
 		if outputPath == "" {
 			outputPath = changeExtensionTo(inputPath, "png")
 		}
 		if filepath.Ext(outputPath) == "" {
 			outputPath += ".png"
 		}
+		if _, ok := encoders[strings.ToLower(filepath.Ext(outputPath))]; !ok {
+			return "", "", nil, fmt.Errorf("unsupported output format %q, want one of %s", filepath.Ext(outputPath), encodeFormats())
+		}
 	}
 	if filepath.Ext(inputPath) != ".icns" {
 		if outputPath == "" {
@@ -109,13 +530,216 @@ func sanitiseInputs(
 			outputPath += ".icns"
 		}
 	}
-	if resize < 0 {
-		resize = 0
+	quality, err := strconv.Atoi(resize)
+	if err != nil {
+		algorithm, ok := resamplers[strings.ToLower(resize)]
+		if !ok {
+			return "", "", nil, fmt.Errorf("unknown algorithm %q", resize)
+		}
+		return inputPath, outputPath, algorithm, nil
+	}
+	if quality < 0 {
+		quality = 0
+	}
+	if quality > 5 {
+		quality = 5
+	}
+	return inputPath, outputPath, icns.Interpolate(icns.InterpolationFunction(quality)), nil
+}
+
+var bitDepths = map[string]icns.BitDepth{
+	"auto": icns.BitDepthAuto,
+	"8":    icns.BitDepth8,
+	"16":   icns.BitDepthPreserve,
+}
+
+var colorSpaces = map[string]icns.ColorSpace{
+	"none": icns.ColorSpaceNone,
+	"srgb": icns.ColorSpaceSRGB,
+	"p3":   icns.ColorSpaceDisplayP3,
+}
+
+var badgeShapes = map[string]icns.BadgeShape{
+	"corner": icns.BadgeCorner,
+	"ribbon": icns.BadgeRibbon,
+}
+
+var corners = map[string]icns.Corner{
+	"top-right":    icns.TopRight,
+	"top-left":     icns.TopLeft,
+	"bottom-right": icns.BottomRight,
+	"bottom-left":  icns.BottomLeft,
+}
+
+var resamplers = map[string]icns.Resampler{
+	"catmullrom":     icns.CatmullRom,
+	"approxbilinear": icns.ApproxBiLinear,
+	"lanczos":        icns.Lanczos{},
+	"pixel":          icns.PixelArt{},
+	"pixel-scale2x":  icns.PixelArt{EdgeDirected: true},
+}
+
+// logStats logs the encoded size of each icon, largest first, and the totals.
+func logStats(e icns.Event) {
+	if e.Kind != icns.Finished {
+		return
+	}
+	ids := make([]string, 0, len(e.Stats.Sizes))
+	for id := range e.Stats.Sizes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(ii, jj int) bool {
+		return e.Stats.Sizes[ids[ii]] > e.Stats.Sizes[ids[jj]]
+	})
+	for _, id := range ids {
+		log.Printf("%s: %d bytes", id, e.Stats.Sizes[id])
+	}
+	log.Printf(
+		"%d icons, %d bytes, %d upscaled, resize %v, encode %v, took %v",
+		e.Stats.Entries,
+		e.Stats.Bytes,
+		e.Stats.Upscaled,
+		e.Stats.Resize,
+		e.Stats.Encode,
+		e.Stats.Elapsed,
+	)
+}
+
+// parseSharpen parses radius,amount[,threshold] into an unsharp mask.
+// No values means no sharpening.
+func parseSharpen(values []float64) (*icns.Sharpen, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if len(values) < 2 || len(values) > 3 {
+		return nil, fmt.Errorf("want radius,amount[,threshold], got %d values", len(values))
+	}
+	s := icns.Sharpen{Radius: values[0], Amount: values[1]}
+	if len(values) == 3 {
+		if values[2] < 0 || values[2] > 255 {
+			return nil, fmt.Errorf("threshold must be between 0 and 255, got %v", values[2])
+		}
+		s.Threshold = uint8(values[2])
+	}
+	return &s, nil
+}
+
+// parseTrim parses a margin percentage, or safe for the macOS safe area.
+// An empty value means no trimming.
+func parseTrim(value string) (*icns.Trim, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return nil, nil
+	case "safe":
+		return &icns.Trim{Margin: icns.SafeArea}, nil
+	}
+	margin, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("want a percentage or safe, got %q", value)
+	}
+	if margin < 0 || margin >= 50 {
+		return nil, fmt.Errorf("margin must be from 0 to 50 percent, got %v", margin)
+	}
+	return &icns.Trim{Margin: margin}, nil
+}
+
+// parseStyle parses the named icon template and its background colour.
+// An empty name means no template.
+func parseStyle(name, background string) (*icns.Style, error) {
+	switch strings.ToLower(name) {
+	case "":
+		if background != "" {
+			return nil, fmt.Errorf("background requires a style")
+		}
+		return nil, nil
+	case "macos":
+	default:
+		return nil, fmt.Errorf("unknown style %q, want macos", name)
 	}
-	if resize > 5 {
-		resize = 5
+	s := icns.Style{Shadow: true}
+	if background != "" {
+		c, err := parseColor(background)
+		if err != nil {
+			return nil, err
+		}
+		s.Background = c
 	}
-	return inputPath, outputPath, icns.InterpolationFunction(resize)
+	return &s, nil
+}
+
+// parseTransforms parses the colour transform flags into filters, applied in
+// the order hue shift, tint, grayscale, template.
+func parseTransforms(hueShift float64, tint string, tintAmount, grayscale float64, template string) ([]icns.Filter, error) {
+	var filters []icns.Filter
+	if hueShift != 0 {
+		filters = append(filters, icns.HueShift{Degrees: hueShift})
+	}
+	if tint != "" {
+		if tintAmount <= 0 || tintAmount > 1 {
+			return nil, fmt.Errorf("tint amount must be above 0 and at most 1, got %v", tintAmount)
+		}
+		c, err := parseColor(tint)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, icns.Tint{Color: c, Amount: tintAmount})
+	}
+	if grayscale < 0 || grayscale > 1 {
+		return nil, fmt.Errorf("grayscale must be from 0 to 1, got %v", grayscale)
+	}
+	if grayscale > 0 {
+		filters = append(filters, icns.Grayscale{Amount: grayscale})
+	}
+	if template != "" {
+		c, err := parseColor(template)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, icns.Template{Color: c})
+	}
+	return filters, nil
+}
+
+// parseBadge parses the badge flags. Empty text means no badge.
+func parseBadge(text, shape, corner, fill, textColor string) (*icns.Badge, error) {
+	if text == "" {
+		return nil, nil
+	}
+	b := icns.Badge{Text: text}
+	var ok bool
+	if b.Shape, ok = badgeShapes[strings.ToLower(shape)]; !ok {
+		return nil, fmt.Errorf("unknown shape %q, want corner or ribbon", shape)
+	}
+	if b.Corner, ok = corners[strings.ToLower(corner)]; !ok {
+		return nil, fmt.Errorf("unknown corner %q, want top-right, top-left, bottom-right or bottom-left", corner)
+	}
+	c, err := parseColor(fill)
+	if err != nil {
+		return nil, err
+	}
+	b.Color = c
+	if c, err = parseColor(textColor); err != nil {
+		return nil, err
+	}
+	b.TextColor = c
+	return &b, nil
+}
+
+// parseColor parses a hex colour as rgb, rrggbb or rrggbbaa, with an optional
+// leading #.
+func parseColor(value string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("want a hex colour like #1e90ff, got %q", value)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
 }
 
 func changeExtensionTo(path, ext string) string {
@@ -131,8 +755,31 @@ func encodeJPEG(w io.Writer, m image.Image) error {
 	return jpeg.Encode(w, m, &jpeg.Options{Quality: 100})
 }
 
+func encodeTIFF(w io.Writer, m image.Image) error {
+	return tiff.Encode(w, m, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
 var encoders = map[string]encoderFunc{
 	".png":  png.Encode,
 	".jpg":  encodeJPEG,
 	".jpeg": encodeJPEG,
+	".gif":  encodeGIF,
+	".bmp":  bmp.Encode,
+	".tif":  encodeTIFF,
+	".tiff": encodeTIFF,
+	".webp": webp.Encode,
+	".ico":  ico.Encode,
+}
+
+// decodeFormats lists the input formats that can be decoded.
+const decodeFormats = "png, jpeg, gif, bmp, tiff, webp, svg, icns, ico, exe, dll"
+
+// encodeFormats lists the extensions that icns can be extracted to.
+func encodeFormats() string {
+	exts := make([]string, 0, len(encoders))
+	for ext := range encoders {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return strings.Join(exts, ", ")
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jackmordaunt/icns/svg"
+	"github.com/jackmordaunt/icns/v3"
+	"github.com/jackmordaunt/icns/v3/ico"
+	"github.com/jackmordaunt/icns/v3/winres"
+)
+
+// writeSyso writes a resource object embedding the icon into dir for each
+// architecture, named rsrc_windows_<arch>.syso so that go build links it
+// into Windows binaries. An ico source is embedded as is; anything else,
+// including the largest image of an icns, is rendered through pipeline.
+func writeSyso(dir string, arches []string, data []byte, source *icns.Loaded, vector *svg.Source, pipeline *icns.Encoder) error {
+	if source.Format != "ico" {
+		var (
+			buf     = bytes.NewBuffer(nil)
+			windows = ico.NewEncoder(buf).WithPipeline(pipeline)
+			err     error
+		)
+		if vector != nil {
+			err = windows.EncodeSource(vector)
+		} else {
+			err = windows.Encode(source.Image)
+		}
+		if err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	group, err := winres.ReadICO(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("reading ico: %w", err)
+	}
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("preparing output directory: %w", err)
+	}
+	for _, arch := range arches {
+		path := filepath.Join(dir, "rsrc_windows_"+arch+".syso")
+		f, err := fs.Create(path)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", path, err)
+		}
+		if err := group.WriteSyso(f, arch); err != nil {
+			f.Close()
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkArches reports an error for any architecture without resource
+// object support.
+func checkArches(arches []string) error {
+	if len(arches) == 0 {
+		return fmt.Errorf("no architectures given")
+	}
+	for _, arch := range arches {
+		supported := false
+		for _, a := range winres.Arches {
+			supported = supported || a == arch
+		}
+		if !supported {
+			return fmt.Errorf("unsupported architecture %q, want one of %v", arch, winres.Arches)
+		}
+	}
+	return nil
+}
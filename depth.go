@@ -0,0 +1,81 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// BitDepth decides the number of bits per channel in the encoded icons.
+type BitDepth int
+
+// BitDepth constants.
+const (
+	// BitDepthAuto keeps 16 bits per channel only if the source actually uses
+	// the extra precision, otherwise it uses 8.
+	BitDepthAuto BitDepth = iota
+	// BitDepth8 always uses 8 bits per channel.
+	BitDepth8
+	// BitDepthPreserve uses 16 bits per channel for 16 bit sources and 8 for
+	// everything else.
+	BitDepthPreserve
+)
+
+// sixteenBit reports whether icons made from img should use 16 bits per
+// channel.
+func (d BitDepth) sixteenBit(img image.Image) bool {
+	switch d {
+	case BitDepth8:
+		return false
+	case BitDepthPreserve:
+		return is16Bit(img)
+	}
+	return is16Bit(img) && usesLowBits(img)
+}
+
+// is16Bit reports whether img has more than 8 bits per channel.
+func is16Bit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
+
+// usesLowBits reports whether any channel of img holds a value that can't be
+// represented in 8 bits.
+func usesLowBits(img image.Image) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			for _, v := range []uint16{c.R, c.G, c.B, c.A} {
+				if v>>8 != v&0xff {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// toDepth returns img as an *image.NRGBA64 if sixteen is set, otherwise as an
+// *image.NRGBA, with its origin at (0, 0).
+func toDepth(img image.Image, sixteen bool) image.Image {
+	if !sixteen {
+		return toNRGBA(img)
+	}
+	return toNRGBA64(img)
+}
+
+// toNRGBA64 returns img as an *image.NRGBA64 with its origin at (0, 0),
+// converting it if required.
+func toNRGBA64(img image.Image) *image.NRGBA64 {
+	if src, ok := img.(*image.NRGBA64); ok && src.Rect.Min == (image.Point{}) {
+		return src
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA64(b.Sub(b.Min))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
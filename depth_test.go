@@ -0,0 +1,60 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBitDepth(t *testing.T) {
+	t.Parallel()
+	source := func(precise bool) *image.NRGBA64 {
+		img := image.NewNRGBA64(image.Rect(0, 0, 64, 64))
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				v := uint16(uint8(x*4+y)) * 0x101
+				if precise {
+					v += 7
+				}
+				img.SetNRGBA64(x, y, color.NRGBA64{R: v, G: v, B: 0xffff - v, A: 0xffff})
+			}
+		}
+		return img
+	}
+	tests := []struct {
+		desc  string
+		depth BitDepth
+		img   image.Image
+		want  uint8
+	}{
+		{"auto keeps precise 16 bit", BitDepthAuto, source(true), 16},
+		{"auto reduces unused 16 bit", BitDepthAuto, source(false), 8},
+		{"preserve keeps 16 bit", BitDepthPreserve, source(false), 16},
+		{"preserve keeps 8 bit", BitDepthPreserve, image.NewNRGBA(image.Rect(0, 0, 64, 64)), 8},
+		{"force 8 bit", BitDepth8, source(true), 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			for _, r := range []Resampler{Interpolate(MitchellNetravali), CatmullRom, Lanczos{}, Linear(Interpolate(Bilinear))} {
+				iconset, err := NewEncoder(nil).
+					WithResampler(r).
+					WithBitDepth(tt.depth).
+					WithSharpen(Sharpen{Radius: 1, Amount: 1}).
+					IconSet(tt.img)
+				if err != nil {
+					st.Fatalf("unexpected error: %v", err)
+				}
+				for _, icon := range iconset.Icons {
+					if icon == nil {
+						continue
+					}
+					// The bit depth is the first byte after the width and
+					// height in the IHDR chunk.
+					if got := icon.data[24]; got != tt.want {
+						st.Errorf("%T %s: want %d bit png, got %d", r, icon.Type.ID, tt.want, got)
+					}
+				}
+			}
+		})
+	}
+}
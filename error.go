@@ -3,6 +3,7 @@ package icns
 import (
 	"fmt"
 	"image"
+	"strings"
 )
 
 // ErrImageTooSmall is returned when the image is too small to process.
@@ -12,9 +13,33 @@ type ErrImageTooSmall struct {
 }
 
 func (err ErrImageTooSmall) Error() string {
-	b := err.image.Bounds().Max
+	b := err.image.Bounds()
 	format := "image is too small: %dx%d, need at least %dx%d"
-	return fmt.Sprintf(format, b.X, b.Y, err.need, err.need)
+	return fmt.Sprintf(format, b.Dx(), b.Dy(), err.need, err.need)
+}
+
+// ErrSizeBudget is returned when the encoded icns is larger than the budget.
+type ErrSizeBudget struct {
+	Budget int
+	Size   int
+	// Over lists the fewest, largest icons that would have to be dropped for
+	// the icns to fit the budget, largest first.
+	Over []EntrySize
+}
+
+// EntrySize is the size of an icon's image data.
+type EntrySize struct {
+	Type  OsType
+	Bytes int
+}
+
+func (err ErrSizeBudget) Error() string {
+	entries := make([]string, len(err.Over))
+	for ii, e := range err.Over {
+		entries[ii] = fmt.Sprintf("%s (%d bytes)", e.Type.ID, e.Bytes)
+	}
+	format := "icns is %d bytes, over the %d byte budget by %d bytes: %s"
+	return fmt.Sprintf(format, err.Size, err.Budget, err.Size-err.Budget, strings.Join(entries, ", "))
 }
 
 func panicf(format string, values ...interface{}) {
@@ -0,0 +1,48 @@
+package icns
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Filter transforms the squared source before it is resized.
+// Filters must not modify the image they are given.
+type Filter interface {
+	Apply(img image.Image) image.Image
+}
+
+// failingFilter is a Filter that can fail, such as Badge. The Encoder uses
+// apply in place of Apply so that it can report the error.
+type failingFilter interface {
+	Filter
+	apply(img image.Image) (image.Image, error)
+}
+
+// applyFilters runs img through each filter in order.
+func applyFilters(img image.Image, filters []Filter) (image.Image, error) {
+	for _, f := range filters {
+		if ff, ok := f.(failingFilter); ok {
+			var err error
+			if img, err = ff.apply(img); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		img = f.Apply(img)
+	}
+	return img, nil
+}
+
+// cloneImage returns a copy of img that can be drawn on, keeping 16 bits per
+// channel for 16 bit images.
+func cloneImage(img image.Image) draw.Image {
+	b := img.Bounds()
+	var dst draw.Image
+	if is16Bit(img) {
+		dst = image.NewNRGBA64(b.Sub(b.Min))
+	} else {
+		dst = image.NewNRGBA(b.Sub(b.Min))
+	}
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
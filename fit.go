@@ -0,0 +1,70 @@
+package icns
+
+import (
+	"image"
+	"image/draw"
+)
+
+// FitMode describes how a non-square source is made square before resizing.
+type FitMode int
+
+// FitMode constants.
+const (
+	// FitStretch squares the image using the largest side without preserving
+	// the aspect ratio.
+	FitStretch FitMode = iota
+	// FitContain scales the whole image into the square, padding the shorter
+	// side with transparent pixels.
+	FitContain
+	// FitCover fills the square with the image, cropping the longer side
+	// around the centre.
+	FitCover
+	// FitCrop uses only the pixels inside a given rectangle.
+	FitCrop
+)
+
+// fit returns img squared according to mode.
+// crop is only used by FitCrop and is clipped to the image bounds.
+func fit(img image.Image, mode FitMode, crop image.Rectangle) image.Image {
+	b := img.Bounds()
+	switch mode {
+	case FitContain:
+		side := b.Dx()
+		if b.Dy() > side {
+			side = b.Dy()
+		}
+		if side == b.Dx() && side == b.Dy() {
+			return img
+		}
+		dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+		offset := image.Pt((side-b.Dx())/2, (side-b.Dy())/2)
+		draw.Draw(dst, b.Sub(b.Min).Add(offset), img, b.Min, draw.Src)
+		return dst
+	case FitCover:
+		side := b.Dx()
+		if b.Dy() < side {
+			side = b.Dy()
+		}
+		min := b.Min.Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+		return subImage(img, image.Rectangle{Min: min, Max: min.Add(image.Pt(side, side))})
+	case FitCrop:
+		return subImage(img, crop.Intersect(b))
+	}
+	return img
+}
+
+// subImage returns the portion of img visible through r, sharing pixels
+// where the concrete image type allows it.
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if r == img.Bounds() {
+		return img
+	}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewNRGBA(r.Sub(r.Min))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,277 @@
+// Package freedesktop exports application icons for Linux desktops, as a
+// hicolor icon theme tree and a .desktop entry.
+//
+// Icons are resized through an icns.Encoder, so the resampler, sharpening,
+// filters and style chosen for an icns file apply to the Linux icons too.
+package freedesktop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackmordaunt/icns/v3"
+)
+
+// Sizes are the icon sizes (in px) written by default, as listed by the
+// hicolor theme.
+var Sizes = []uint{512, 256, 128, 64, 48, 32, 24, 22, 16}
+
+// Exporter creates the files of an application icon for the share directory
+// of a prefix, such as a package's usr directory.
+type Exporter struct {
+	// Name of the icon, usually the name of the executable.
+	Name string
+	// Pipeline resizes the source to each size.
+	// Defaults to icns.NewEncoder(nil) if nil.
+	Pipeline *icns.Encoder
+	// Sizes of the icons (in px). Defaults to Sizes if empty.
+	Sizes []uint
+	// Scalable, if set, is an svg written as the scalable icon. It is written
+	// unchanged, so the pipeline must not alter the artwork; see
+	// ScalableMatches.
+	Scalable []byte
+	// Desktop, if set, is written as the application's .desktop entry.
+	Desktop *DesktopEntry
+}
+
+// DesktopEntry describes an application for menus and launchers.
+// See the Desktop Entry Specification.
+type DesktopEntry struct {
+	// Name shown to users. Defaults to the icon name if empty.
+	Name string
+	// Comment is a tooltip, such as "Edit photos".
+	Comment string
+	// Exec is the program that runs the application and its arguments,
+	// quoted as the specification requires. Arguments may be field codes
+	// such as %F. Defaults to the icon name if empty.
+	Exec []string
+	// Categories of the menus the application appears in, such as Graphics.
+	Categories []string
+	// Terminal runs the application in a terminal.
+	Terminal bool
+}
+
+// File is a file of an exported icon, at Path relative to the prefix.
+type File struct {
+	Path string
+	Data []byte
+}
+
+// NewExporter initialises an exporter of the icon name.
+func NewExporter(name string) *Exporter {
+	return &Exporter{Name: name}
+}
+
+// WithPipeline resizes icons with p, applying all of its options.
+func (e *Exporter) WithPipeline(p *icns.Encoder) *Exporter {
+	e.Pipeline = p
+	return e
+}
+
+// WithSizes writes an icon for each size (in px).
+func (e *Exporter) WithSizes(sizes ...uint) *Exporter {
+	e.Sizes = sizes
+	return e
+}
+
+// WithScalable writes svg as the scalable icon.
+func (e *Exporter) WithScalable(svg []byte) *Exporter {
+	e.Scalable = svg
+	return e
+}
+
+// WithDesktop writes d as the application's .desktop entry.
+func (e *Exporter) WithDesktop(d DesktopEntry) *Exporter {
+	e.Desktop = &d
+	return e
+}
+
+// Export returns the files of the icons resized from img, leaving out sizes
+// larger than img unless the pipeline upscales.
+func (e *Exporter) Export(img image.Image) ([]File, error) {
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	images, err := e.pipeline().Images(img, e.sizes())
+	if err != nil {
+		return nil, err
+	}
+	return e.files(images)
+}
+
+// ExportSource returns the files of the icons of a source rendered at each
+// size.
+func (e *Exporter) ExportSource(p icns.SourceProvider) ([]File, error) {
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	images, err := e.pipeline().ImagesSource(p, e.sizes())
+	if err != nil {
+		return nil, err
+	}
+	return e.files(images)
+}
+
+// ScalableMatches reports whether an svg still matches the icons rendered
+// from it through p, which is not so once p fits, crops, filters, styles or
+// trims them, or converts them out of sRGB, the colour space of svg.
+func ScalableMatches(p *icns.Encoder) bool {
+	if p == nil {
+		return true
+	}
+	return p.Fit == icns.FitStretch &&
+		p.Crop.Empty() &&
+		len(p.Filters) == 0 &&
+		p.Style == nil &&
+		p.Trim == nil &&
+		(p.ColorSpace == icns.ColorSpaceNone || p.ColorSpace == icns.ColorSpaceSRGB)
+}
+
+// IconPath returns where the icon of size (in px) is written, relative to
+// the prefix.
+func IconPath(name string, size uint) string {
+	return filepath.Join("share", "icons", "hicolor", fmt.Sprintf("%dx%d", size, size), "apps", name+".png")
+}
+
+// ScalablePath returns where the scalable icon is written, relative to the
+// prefix.
+func ScalablePath(name string) string {
+	return filepath.Join("share", "icons", "hicolor", "scalable", "apps", name+".svg")
+}
+
+// DesktopPath returns where the .desktop entry is written, relative to the
+// prefix.
+func DesktopPath(name string) string {
+	return filepath.Join("share", "applications", name+".desktop")
+}
+
+func (e *Exporter) check() error {
+	if e.Name == "" || e.Name == "." || e.Name == ".." || strings.ContainsAny(e.Name, `/\`) {
+		return fmt.Errorf("invalid icon name %q", e.Name)
+	}
+	if e.Scalable != nil && !ScalableMatches(e.Pipeline) {
+		return errors.New("scalable icon would not match icons that are filtered, styled or trimmed")
+	}
+	return nil
+}
+
+func (e *Exporter) pipeline() *icns.Encoder {
+	if e.Pipeline == nil {
+		return icns.NewEncoder(nil)
+	}
+	return e.Pipeline
+}
+
+func (e *Exporter) sizes() []uint {
+	if len(e.Sizes) == 0 {
+		return Sizes
+	}
+	return e.Sizes
+}
+
+// files returns each image as the icon of its size, then the scalable icon
+// and the .desktop entry if set.
+func (e *Exporter) files(images []image.Image) ([]File, error) {
+	var files []File
+	for _, img := range images {
+		buf := bytes.NewBuffer(nil)
+		if err := png.Encode(buf, img); err != nil {
+			return nil, fmt.Errorf("encoding %dpx icon: %w", img.Bounds().Dx(), err)
+		}
+		files = append(files, File{Path: IconPath(e.Name, uint(img.Bounds().Dx())), Data: buf.Bytes()})
+	}
+	if e.Scalable != nil {
+		files = append(files, File{Path: ScalablePath(e.Name), Data: e.Scalable})
+	}
+	if e.Desktop != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := e.Desktop.Encode(buf, e.Name); err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: DesktopPath(e.Name), Data: buf.Bytes()})
+	}
+	return files, nil
+}
+
+// Encode writes d as a .desktop file for an application with the given icon
+// name.
+func (d DesktopEntry) Encode(w io.Writer, icon string) error {
+	if icon == "" {
+		return errors.New("desktop entry needs an icon name")
+	}
+	var (
+		name = d.Name
+		exec = d.Exec
+	)
+	if name == "" {
+		name = icon
+	}
+	if len(exec) == 0 {
+		exec = []string{icon}
+	}
+	lines := []string{
+		"[Desktop Entry]",
+		"Type=Application",
+		"Name=" + escape(name),
+	}
+	if d.Comment != "" {
+		lines = append(lines, "Comment="+escape(d.Comment))
+	}
+	lines = append(lines,
+		"Exec="+escape(quoteExec(exec)),
+		"Icon="+escape(icon),
+		fmt.Sprintf("Terminal=%t", d.Terminal),
+	)
+	if len(d.Categories) > 0 {
+		var categories strings.Builder
+		for _, c := range d.Categories {
+			categories.WriteString(escapeListItem(c) + ";")
+		}
+		lines = append(lines, "Categories="+categories.String())
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// escape escapes a value as the Desktop Entry Specification requires, so
+// that it stays on its line.
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(value)
+}
+
+// escapeListItem escapes an item of a list value, where ; separates items.
+func escapeListItem(value string) string {
+	return strings.ReplaceAll(escape(value), ";", `\;`)
+}
+
+// fieldCodes are the Exec arguments expanded by launchers.
+var fieldCodes = map[string]bool{
+	"%f": true, "%F": true, "%u": true, "%U": true,
+	"%i": true, "%c": true, "%k": true,
+}
+
+// quoteExec joins the Exec arguments, quoting those with reserved characters
+// and doubling literal percent signs. The result still needs escape, which
+// launchers undo first.
+func quoteExec(args []string) string {
+	quoted := make([]string, len(args))
+	for ii, arg := range args {
+		if fieldCodes[arg] {
+			quoted[ii] = arg
+			continue
+		}
+		arg = strings.ReplaceAll(arg, "%", "%%")
+		if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+			quoted[ii] = arg
+			continue
+		}
+		quoted[ii] = `"` + strings.NewReplacer(`"`, `\"`, "`", "\\`", `$`, `\$`, `\`, `\\`).Replace(arg) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
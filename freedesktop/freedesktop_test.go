@@ -0,0 +1,165 @@
+package freedesktop
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/jackmordaunt/icns/v3"
+)
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+	var (
+		src = image.NewNRGBA(image.Rect(0, 0, 100, 100))
+		svg = []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)
+	)
+	files, err := NewExporter("myapp").
+		WithPipeline(icns.NewEncoder(nil).WithAlgorithm(icns.NearestNeighbor)).
+		WithScalable(svg).
+		WithDesktop(DesktopEntry{Name: "My App"}).
+		Export(src)
+	if err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	byPath := map[string][]byte{}
+	for _, f := range files {
+		byPath[f.Path] = f.Data
+	}
+	for _, size := range Sizes {
+		data, ok := byPath[IconPath("myapp", size)]
+		// Sizes larger than the source are left out.
+		if size > 100 {
+			if ok {
+				t.Errorf("%dpx: want no icon, got one", size)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%dpx: want icon, got none", size)
+			continue
+		}
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%dpx: decoding: %v", size, err)
+			continue
+		}
+		if cfg.Width != int(size) || cfg.Height != int(size) {
+			t.Errorf("%dpx: got %dx%d", size, cfg.Width, cfg.Height)
+		}
+	}
+	if got := byPath[ScalablePath("myapp")]; !bytes.Equal(got, svg) {
+		t.Errorf("want scalable icon unchanged, got %q", got)
+	}
+	if desktop := byPath[DesktopPath("myapp")]; !bytes.Contains(desktop, []byte("\nIcon=myapp\n")) {
+		t.Errorf("want desktop entry with the icon name, got %q", desktop)
+	}
+}
+
+func TestExportName(t *testing.T) {
+	t.Parallel()
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if _, err := NewExporter(name).Export(src); err == nil {
+			t.Errorf("%q: want error, got nil", name)
+		}
+	}
+}
+
+func TestExportScalable(t *testing.T) {
+	t.Parallel()
+	var (
+		src = image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		svg = []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)
+	)
+	tests := []struct {
+		desc     string
+		pipeline *icns.Encoder
+		ok       bool
+	}{
+		{"default", nil, true},
+		{"resize only", icns.NewEncoder(nil).WithAlgorithm(icns.Bilinear), true},
+		{"filtered", icns.NewEncoder(nil).WithFilters(icns.Grayscale{Amount: 1}), false},
+		{"styled", icns.NewEncoder(nil).WithStyle(icns.Style{}), false},
+		{"trimmed", icns.NewEncoder(nil).WithTrim(icns.Trim{}), false},
+		{"fitted", icns.NewEncoder(nil).WithFit(icns.FitContain), false},
+		{"cropped", icns.NewEncoder(nil).WithCrop(image.Rect(0, 0, 8, 8)), false},
+		{"srgb", icns.NewEncoder(nil).WithColorSpace(icns.ColorSpaceSRGB), true},
+		{"display p3", icns.NewEncoder(nil).WithColorSpace(icns.ColorSpaceDisplayP3), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			_, err := NewExporter("myapp").WithPipeline(tt.pipeline).WithScalable(svg).Export(src)
+			if (err == nil) != tt.ok {
+				st.Errorf("want ok %t, got %v", tt.ok, err)
+			}
+			if ScalableMatches(tt.pipeline) != tt.ok {
+				st.Errorf("ScalableMatches: want %t", tt.ok)
+			}
+		})
+	}
+}
+
+func TestDesktopEntry(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc  string
+		entry DesktopEntry
+		want  string
+	}{
+		{
+			"defaults",
+			DesktopEntry{},
+			"[Desktop Entry]\n" +
+				"Type=Application\n" +
+				"Name=myapp\n" +
+				"Exec=myapp\n" +
+				"Icon=myapp\n" +
+				"Terminal=false\n",
+		},
+		{
+			"full",
+			DesktopEntry{
+				Name:       "My App",
+				Comment:    "Line one\nline two",
+				Exec:       []string{"myapp", "--open", "%F"},
+				Categories: []string{"Graphics", "Viewer"},
+				Terminal:   true,
+			},
+			"[Desktop Entry]\n" +
+				"Type=Application\n" +
+				"Name=My App\n" +
+				`Comment=Line one\nline two` + "\n" +
+				"Exec=myapp --open %F\n" +
+				"Icon=myapp\n" +
+				"Terminal=true\n" +
+				"Categories=Graphics;Viewer;\n",
+		},
+		{
+			"quoting",
+			DesktopEntry{
+				Exec:       []string{"/opt/my app/run", "--title=$HOME", `C:\x`, "100%", `say "hi"`, "%u", "`id`", ""},
+				Categories: []string{"A;B", `C\D`},
+			},
+			"[Desktop Entry]\n" +
+				"Type=Application\n" +
+				"Name=myapp\n" +
+				"Exec=\"/opt/my app/run\" \"--title=\\\\$HOME\" \"C:\\\\\\\\x\" 100%% \"say \\\\\"hi\\\\\"\" %u \"\\\\`id\\\\`\" \"\"\n" +
+				"Icon=myapp\n" +
+				"Terminal=false\n" +
+				"Categories=A\\;B;C\\\\D;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			if err := tt.entry.Encode(buf, "myapp"); err != nil {
+				st.Fatalf("encoding: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				st.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
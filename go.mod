@@ -2,4 +2,9 @@ module github.com/jackmordaunt/icns/v3
 
 go 1.21.5
 
-require github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+require (
+	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	golang.org/x/image v0.14.0
+)
+
+require golang.org/x/text v0.14.0 // indirect
@@ -0,0 +1,578 @@
+package icns
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// ColorSpace is the colour space icons are converted to and tagged with.
+type ColorSpace int
+
+// ColorSpace constants.
+const (
+	// ColorSpaceNone leaves colours untouched and untagged.
+	ColorSpaceNone ColorSpace = iota
+	// ColorSpaceSRGB converts to sRGB and tags icons with an sRGB chunk.
+	ColorSpaceSRGB
+	// ColorSpaceDisplayP3 converts to Display P3 and tags icons with an
+	// embedded Display P3 profile.
+	ColorSpaceDisplayP3
+)
+
+// Profile is an ICC colour profile for an RGB matrix/TRC colour space, which
+// covers the profiles commonly embedded by design tools such as sRGB,
+// Display P3 and Adobe RGB.
+type Profile struct {
+	// Description is the profile's human readable name.
+	Description string
+	// matrix converts linear RGB to PCS XYZ (D50).
+	matrix [3][3]float64
+	curves [3]curve
+}
+
+// ErrUnsupportedProfile is returned for ICC profiles that aren't RGB
+// matrix/TRC profiles, such as CMYK or LUT based profiles.
+var ErrUnsupportedProfile = errors.New("unsupported icc profile")
+
+// ParseProfile parses an ICC profile.
+func ParseProfile(data []byte) (*Profile, error) {
+	if len(data) < 132 {
+		return nil, fmt.Errorf("icc profile too short: %d bytes", len(data))
+	}
+	if string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("invalid icc profile signature")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("%w: %q to %q", ErrUnsupportedProfile, data[16:20], data[20:24])
+	}
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for ii := 0; ii < count; ii++ {
+		entry := 132 + ii*12
+		if entry+12 > len(data) {
+			return nil, fmt.Errorf("icc tag table truncated")
+		}
+		var (
+			sig    = string(data[entry : entry+4])
+			offset = int(binary.BigEndian.Uint32(data[entry+4:]))
+			size   = int(binary.BigEndian.Uint32(data[entry+8:]))
+		)
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("icc tag %q out of bounds", sig)
+		}
+		tags[sig] = data[offset : offset+size]
+	}
+	p := &Profile{Description: parseText(tags["desc"])}
+	for ii, name := range []string{"r", "g", "b"} {
+		xyz, ok := tags[name+"XYZ"]
+		if !ok || len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+			return nil, fmt.Errorf("%w: missing %sXYZ tag", ErrUnsupportedProfile, name)
+		}
+		for jj := 0; jj < 3; jj++ {
+			p.matrix[jj][ii] = s15Fixed16(xyz[8+jj*4:])
+		}
+		c, err := parseCurve(tags[name+"TRC"])
+		if err != nil {
+			return nil, fmt.Errorf("%sTRC: %w", name, err)
+		}
+		p.curves[ii] = c
+	}
+	return p, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// parseText reads a v2 textDescriptionType or the first record of a v4
+// multiLocalizedUnicodeType.
+func parseText(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if 12+n > len(tag) || n == 0 {
+			return ""
+		}
+		return string(bytes.TrimRight(tag[12:12+n], "\x00"))
+	case "mluc":
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:12]) == 0 {
+			return ""
+		}
+		var (
+			n      = int(binary.BigEndian.Uint32(tag[20:24]))
+			offset = int(binary.BigEndian.Uint32(tag[24:28]))
+		)
+		if offset+n > len(tag) {
+			return ""
+		}
+		units := make([]uint16, n/2)
+		for ii := range units {
+			units[ii] = binary.BigEndian.Uint16(tag[offset+ii*2:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
+
+// curve is a tone reproduction curve, mapping encoded values to linear light.
+type curve interface {
+	linear(v float64) float64
+}
+
+type gammaCurve float64
+
+func (g gammaCurve) linear(v float64) float64 {
+	return math.Pow(v, float64(g))
+}
+
+type tableCurve []float64
+
+func (t tableCurve) linear(v float64) float64 {
+	var (
+		pos  = v * float64(len(t)-1)
+		ii   = int(pos)
+		frac = pos - float64(ii)
+	)
+	if ii >= len(t)-1 {
+		return t[len(t)-1]
+	}
+	return t[ii] + (t[ii+1]-t[ii])*frac
+}
+
+// paraCurve is an ICC parametricCurveType. Unused parameters are zero.
+type paraCurve struct {
+	function         int
+	g, a, b, c, d, e float64
+	f                float64
+}
+
+func (p paraCurve) linear(v float64) float64 {
+	pow := func(x float64) float64 {
+		if x < 0 {
+			return 0
+		}
+		return math.Pow(x, p.g)
+	}
+	switch p.function {
+	case 0:
+		return pow(v)
+	case 1:
+		if v >= -p.b/p.a {
+			return pow(p.a*v + p.b)
+		}
+		return 0
+	case 2:
+		if v >= -p.b/p.a {
+			return pow(p.a*v+p.b) + p.c
+		}
+		return p.c
+	case 3:
+		if v >= p.d {
+			return pow(p.a*v + p.b)
+		}
+		return p.c * v
+	default:
+		if v >= p.d {
+			return pow(p.a*v+p.b) + p.e
+		}
+		return p.c*v + p.f
+	}
+}
+
+// srgbCurve is the sRGB transfer function, shared by Display P3.
+var srgbCurve = paraCurve{function: 3, g: 2.4, a: 1 / 1.055, b: 0.055 / 1.055, c: 1 / 12.92, d: 0.04045}
+
+func parseCurve(tag []byte) (curve, error) {
+	if len(tag) < 12 {
+		return nil, fmt.Errorf("%w: missing curve", ErrUnsupportedProfile)
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if len(tag) < 12+n*2 {
+			return nil, fmt.Errorf("curve truncated")
+		}
+		switch n {
+		case 0:
+			return gammaCurve(1), nil
+		case 1:
+			return gammaCurve(float64(binary.BigEndian.Uint16(tag[12:])) / 256), nil
+		}
+		t := make(tableCurve, n)
+		for ii := range t {
+			t[ii] = float64(binary.BigEndian.Uint16(tag[12+ii*2:])) / 0xffff
+		}
+		return t, nil
+	case "para":
+		function := int(binary.BigEndian.Uint16(tag[8:10]))
+		counts := []int{1, 3, 4, 5, 7}
+		if function >= len(counts) || len(tag) < 12+counts[function]*4 {
+			return nil, fmt.Errorf("%w: parametric curve %d", ErrUnsupportedProfile, function)
+		}
+		var params [7]float64
+		for ii := 0; ii < counts[function]; ii++ {
+			params[ii] = s15Fixed16(tag[12+ii*4:])
+		}
+		return paraCurve{
+			function: function,
+			g:        params[0],
+			a:        params[1],
+			b:        params[2],
+			c:        params[3],
+			d:        params[4],
+			e:        params[5],
+			f:        params[6],
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: curve type %q", ErrUnsupportedProfile, tag[:4])
+}
+
+// Built in profiles, with primaries adapted to the D50 PCS illuminant.
+var (
+	sRGBProfile = &Profile{
+		Description: "sRGB",
+		matrix: [3][3]float64{
+			{0.4360747, 0.3850649, 0.1430804},
+			{0.2225045, 0.7168786, 0.0606169},
+			{0.0139322, 0.0971045, 0.7141733},
+		},
+		curves: [3]curve{srgbCurve, srgbCurve, srgbCurve},
+	}
+	displayP3Profile = &Profile{
+		Description: "Display P3",
+		matrix: [3][3]float64{
+			{0.5151024, 0.2919648, 0.1571530},
+			{0.2411822, 0.6922360, 0.0665818},
+			{-0.0010497, 0.0418822, 0.7843776},
+		},
+		curves: [3]curve{srgbCurve, srgbCurve, srgbCurve},
+	}
+)
+
+// profile returns the built in profile for the colour space.
+func (cs ColorSpace) profile() *Profile {
+	switch cs {
+	case ColorSpaceSRGB:
+		return sRGBProfile
+	case ColorSpaceDisplayP3:
+		return displayP3Profile
+	}
+	return nil
+}
+
+// convertColor converts img from the src profile to the dst profile.
+// The source curves are sampled into lookup tables, and the destination is
+// encoded with the sRGB curve, which both built in profiles use.
+// The result is an *image.NRGBA64 if sixteen is set, otherwise an
+// *image.NRGBA.
+func convertColor(img image.Image, src, dst *Profile, sixteen bool) image.Image {
+	var (
+		_, encode = luts()
+		m         = multiply(invert(dst.matrix), src.matrix)
+		lut       [3][]float64
+		bounds    = img.Bounds()
+		out       = image.NewNRGBA64(bounds.Sub(bounds.Min))
+	)
+	for ii, c := range src.curves {
+		lut[ii] = make([]float64, 1<<16)
+		for v := range lut[ii] {
+			lut[ii][v] = c.linear(float64(v) / 0xffff)
+		}
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var (
+				c       = color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+				r, g, b = lut[0][c.R], lut[1][c.G], lut[2][c.B]
+				rgb     [3]uint16
+			)
+			for ii, row := range m {
+				v := row[0]*r + row[1]*g + row[2]*b
+				rgb[ii] = encode[uint16(math.Max(0, math.Min(1, v))*0xffff+0.5)]
+			}
+			out.SetNRGBA64(x-bounds.Min.X, y-bounds.Min.Y, color.NRGBA64{
+				R: rgb[0],
+				G: rgb[1],
+				B: rgb[2],
+				A: c.A,
+			})
+		}
+	}
+	return toDepth(out, sixteen)
+}
+
+func multiply(a, b [3][3]float64) (m [3][3]float64) {
+	for ii := 0; ii < 3; ii++ {
+		for jj := 0; jj < 3; jj++ {
+			for kk := 0; kk < 3; kk++ {
+				m[ii][jj] += a[ii][kk] * b[kk][jj]
+			}
+		}
+	}
+	return m
+}
+
+func invert(m [3][3]float64) (inv [3][3]float64) {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	inv[0][0] = (m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det
+	inv[0][1] = (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det
+	inv[0][2] = (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det
+	inv[1][0] = (m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det
+	inv[1][1] = (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det
+	inv[1][2] = (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det
+	inv[2][0] = (m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det
+	inv[2][1] = (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det
+	inv[2][2] = (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det
+	return inv
+}
+
+// ExtractProfile returns the ICC profile embedded in png (iCCP chunk) or
+// jpeg (APP2 segments) data. It returns nil if there is no profile.
+func ExtractProfile(data []byte) (*Profile, error) {
+	var (
+		raw []byte
+		err error
+	)
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		raw, err = pngProfile(data)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		raw, err = jpegProfile(data)
+	}
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return ParseProfile(raw)
+}
+
+func pngProfile(data []byte) (raw []byte, err error) {
+	walkErr := pngChunks(data, func(name string, chunk []byte) bool {
+		switch name {
+		case "iCCP":
+			nul := bytes.IndexByte(chunk, 0)
+			if nul < 0 || nul+2 > len(chunk) {
+				err = fmt.Errorf("invalid iCCP chunk")
+				return false
+			}
+			zr, zerr := zlib.NewReader(bytes.NewReader(chunk[nul+2:]))
+			if zerr != nil {
+				err = fmt.Errorf("decompressing iCCP chunk: %w", zerr)
+				return false
+			}
+			defer zr.Close()
+			raw, err = io.ReadAll(zr)
+			return false
+		case "IDAT":
+			return false
+		}
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return raw, err
+}
+
+// pngChunks calls f with each chunk of png data until f returns false or
+// IEND is reached.
+func pngChunks(data []byte, f func(name string, chunk []byte) bool) error {
+	for offset := len(pngSignature); offset+8 <= len(data); {
+		var (
+			size = int(binary.BigEndian.Uint32(data[offset:]))
+			name = string(data[offset+4 : offset+8])
+			end  = offset + 8 + size
+		)
+		if size < 0 || end > len(data) {
+			return fmt.Errorf("png chunk %q truncated", name)
+		}
+		if name == "IEND" || !f(name, data[offset+8:end]) {
+			return nil
+		}
+		offset = end + 4
+	}
+	return nil
+}
+
+var jpegICCMarker = []byte("ICC_PROFILE\x00")
+
+func jpegProfile(data []byte) ([]byte, error) {
+	chunks := map[int][]byte{}
+	err := jpegSegments(data, func(marker byte, segment []byte) {
+		if marker == 0xe2 && bytes.HasPrefix(segment, jpegICCMarker) && len(segment) > len(jpegICCMarker)+2 {
+			seq := int(segment[len(jpegICCMarker)])
+			chunks[seq] = segment[len(jpegICCMarker)+2:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	var raw []byte
+	for seq := 1; seq <= len(chunks); seq++ {
+		chunk, ok := chunks[seq]
+		if !ok {
+			return nil, fmt.Errorf("icc profile chunk %d missing", seq)
+		}
+		raw = append(raw, chunk...)
+	}
+	return raw, nil
+}
+
+// jpegSegments calls f with the marker and payload of each segment of jpeg
+// data up to the start of the image data.
+func jpegSegments(data []byte, f func(marker byte, segment []byte)) error {
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xff {
+			return fmt.Errorf("invalid jpeg marker at %d", offset)
+		}
+		marker := data[offset+1]
+		if marker == 0xd8 || (marker >= 0xd0 && marker <= 0xd7) || marker == 0x01 || marker == 0xff {
+			offset += 2
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			break // image data follows, no more metadata.
+		}
+		var (
+			size = int(binary.BigEndian.Uint16(data[offset+2:]))
+			end  = offset + 2 + size
+		)
+		if end > len(data) {
+			return fmt.Errorf("jpeg segment truncated")
+		}
+		f(marker, data[offset+4:end])
+		offset = end
+	}
+	return nil
+}
+
+// encode writes a built in profile as an ICC v4 display profile.
+// Built in profiles use the sRGB curve and a D65 white point.
+func (p *Profile) encode() []byte {
+	var (
+		xyz = func(x, y, z float64) []byte {
+			b := []byte("XYZ \x00\x00\x00\x00")
+			for _, v := range []float64{x, y, z} {
+				b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+			}
+			return b
+		}
+		text = func(s string) []byte {
+			units := utf16.Encode([]rune(s))
+			b := []byte("mluc\x00\x00\x00\x00")
+			b = binary.BigEndian.AppendUint32(b, 1)
+			b = binary.BigEndian.AppendUint32(b, 12)
+			b = append(b, "enUS"...)
+			b = binary.BigEndian.AppendUint32(b, uint32(len(units)*2))
+			b = binary.BigEndian.AppendUint32(b, 28)
+			for _, u := range units {
+				b = binary.BigEndian.AppendUint16(b, u)
+			}
+			return b
+		}
+		para = []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+		tags = []struct {
+			sig  string
+			data []byte
+		}{
+			{"desc", text(p.Description)},
+			{"cprt", text("No copyright, use freely")},
+			{"wtpt", xyz(0.9642, 1, 0.8249)},
+			{"rXYZ", xyz(p.matrix[0][0], p.matrix[1][0], p.matrix[2][0])},
+			{"gXYZ", xyz(p.matrix[0][1], p.matrix[1][1], p.matrix[2][1])},
+			{"bXYZ", xyz(p.matrix[0][2], p.matrix[1][2], p.matrix[2][2])},
+			{"rTRC", nil},
+			{"gTRC", nil},
+			{"bTRC", nil},
+			{"chad", nil},
+		}
+	)
+	for _, v := range []float64{srgbCurve.g, srgbCurve.a, srgbCurve.b, srgbCurve.c, srgbCurve.d} {
+		para = binary.BigEndian.AppendUint32(para, uint32(int32(math.Round(v*65536))))
+	}
+	// Both built in profiles have a D65 white point, adapted to D50 with the
+	// Bradford transform.
+	chad := []byte("sf32\x00\x00\x00\x00")
+	for _, v := range []float64{
+		1.0478112, 0.0228866, -0.0501270,
+		0.0295424, 0.9904844, -0.0170491,
+		-0.0092345, 0.0150436, 0.7521316,
+	} {
+		chad = binary.BigEndian.AppendUint32(chad, uint32(int32(math.Round(v*65536))))
+	}
+	tags[6].data, tags[7].data, tags[8].data, tags[9].data = para, para, para, chad
+	var (
+		header = make([]byte, 128)
+		table  = binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+		body   []byte
+		start  = 128 + 4 + len(tags)*12
+	)
+	for _, t := range tags {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		table = append(table, t.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(start+len(body)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+		body = append(body, t.data...)
+	}
+	size := start + len(body)
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	binary.BigEndian.PutUint32(header[8:], 0x04300000)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2024)
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	for ii, v := range []float64{0.9642, 1, 0.8249} {
+		binary.BigEndian.PutUint32(header[68+ii*4:], uint32(int32(math.Round(v*65536))))
+	}
+	out := append(header, table...)
+	return append(out, body...)
+}
+
+// tagColorSpace inserts the chunk identifying cs into png data, directly
+// after the IHDR chunk.
+func tagColorSpace(data []byte, cs ColorSpace) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	switch cs {
+	case ColorSpaceSRGB:
+		// Perceptual rendering intent.
+		writeChunk(buf, "sRGB", []byte{0})
+	case ColorSpaceDisplayP3:
+		profile, err := deflate(displayP3Profile.encode(), zlib.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		name := append([]byte(displayP3Profile.Description), 0, 0)
+		writeChunk(buf, "iCCP", append(name, profile...))
+	default:
+		return data, nil
+	}
+	// Signature, then IHDR's length, name, 13 bytes of data and crc.
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("invalid png")
+	}
+	out := make([]byte, 0, len(data)+buf.Len())
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, buf.Bytes()...)
+	return append(out, data[ihdrEnd:]...), nil
+}
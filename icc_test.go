@@ -0,0 +1,130 @@
+package icns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/jackmordaunt/icns/v3/internal/paint"
+)
+
+func TestProfileRoundTrip(t *testing.T) {
+	t.Parallel()
+	p, err := ParseProfile(displayP3Profile.encode())
+	if err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+	if p.Description != "Display P3" {
+		t.Errorf("description: want=%q, got=%q", "Display P3", p.Description)
+	}
+	for ii := range p.matrix {
+		for jj := range p.matrix[ii] {
+			if d := math.Abs(p.matrix[ii][jj] - displayP3Profile.matrix[ii][jj]); d > 1e-4 {
+				t.Errorf("matrix[%d][%d]: want=%v, got=%v", ii, jj, displayP3Profile.matrix[ii][jj], p.matrix[ii][jj])
+			}
+		}
+	}
+	for _, v := range []float64{0, 0.01, 0.04045, 0.2, 0.5, 1} {
+		if d := math.Abs(p.curves[0].linear(v) - paint.SRGBToLinear(v)); d > 1e-4 {
+			t.Errorf("curve at %v: want=%v, got=%v", v, paint.SRGBToLinear(v), p.curves[0].linear(v))
+		}
+	}
+}
+
+func TestExtractProfile(t *testing.T) {
+	t.Parallel()
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	t.Run("png", func(st *testing.T) {
+		data, err := encodeImage(img)
+		if err != nil {
+			st.Fatalf("encoding: %v", err)
+		}
+		if p, err := ExtractProfile(data); err != nil || p != nil {
+			st.Fatalf("untagged: want no profile, got %v, %v", p, err)
+		}
+		data, err = tagColorSpace(data, ColorSpaceDisplayP3)
+		if err != nil {
+			st.Fatalf("tagging: %v", err)
+		}
+		p, err := ExtractProfile(data)
+		if err != nil {
+			st.Fatalf("extracting: %v", err)
+		}
+		if p == nil || p.Description != "Display P3" {
+			st.Fatalf("want Display P3 profile, got %+v", p)
+		}
+	})
+	t.Run("jpeg", func(st *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := jpeg.Encode(buf, img, nil); err != nil {
+			st.Fatalf("encoding: %v", err)
+		}
+		// Split the profile over two APP2 segments, directly after SOI.
+		var (
+			raw      = displayP3Profile.encode()
+			half     = len(raw) / 2
+			segments []byte
+		)
+		for ii, chunk := range [][]byte{raw[:half], raw[half:]} {
+			segment := append(append([]byte{}, jpegICCMarker...), byte(ii+1), 2)
+			segment = append(segment, chunk...)
+			segments = append(segments, 0xff, 0xe2)
+			segments = binary.BigEndian.AppendUint16(segments, uint16(len(segment)+2))
+			segments = append(segments, segment...)
+		}
+		data := append(append([]byte{0xff, 0xd8}, segments...), buf.Bytes()[2:]...)
+		p, err := ExtractProfile(data)
+		if err != nil {
+			st.Fatalf("extracting: %v", err)
+		}
+		if p == nil || p.Description != "Display P3" {
+			st.Fatalf("want Display P3 profile, got %+v", p)
+		}
+	})
+}
+
+func TestConvertColor(t *testing.T) {
+	t.Parallel()
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	got := convertColor(img, sRGBProfile, displayP3Profile, false).(*image.NRGBA).NRGBAAt(0, 0)
+	// sRGB red expressed in Display P3.
+	want := color.NRGBA{R: 234, G: 51, B: 35, A: 0xff}
+	for _, c := range [][2]uint8{{got.R, want.R}, {got.G, want.G}, {got.B, want.B}} {
+		if d := int(c[0]) - int(c[1]); d < -2 || d > 2 {
+			t.Fatalf("want=%v, got=%v", want, got)
+		}
+	}
+	back := convertColor(convertColor(img, sRGBProfile, displayP3Profile, true), displayP3Profile, sRGBProfile, false)
+	if c := back.(*image.NRGBA).NRGBAAt(0, 0); c != img.NRGBAAt(0, 0) {
+		t.Errorf("round trip: want=%v, got=%v", img.NRGBAAt(0, 0), c)
+	}
+}
+
+func TestEncoderColorSpace(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		cs    ColorSpace
+		chunk string
+	}{
+		{ColorSpaceSRGB, "sRGB"},
+		{ColorSpaceDisplayP3, "iCCP"},
+	} {
+		iconset, err := NewEncoder(nil).
+			WithColorSpace(tt.cs).
+			WithSourceProfile(displayP3Profile).
+			IconSet(rect(0, 0, 64, 64))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, icon := range iconset.Icons {
+			if icon != nil && !bytes.Contains(icon.data, []byte(tt.chunk)) {
+				t.Errorf("%s: want %s chunk", icon.Type.ID, tt.chunk)
+			}
+		}
+	}
+}
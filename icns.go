@@ -1,20 +1,89 @@
 package icns
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"image"
 	"io"
+	"runtime"
+	"sort"
 	"sync"
-
-	"github.com/nfnt/resize"
+	"time"
 )
 
 // Encoder encodes ICNS files from a source image.
 type Encoder struct {
-	Wr        io.Writer
+	Wr io.Writer
+	// Algorithm resizes the source to each icon size, unless Resampler is set.
 	Algorithm InterpolationFunction
+	// Resampler, if set, resizes the source to each icon size instead of
+	// Algorithm.
+	Resampler Resampler
+	// LinearLight resizes in linear light with premultiplied alpha.
+	// See Linear.
+	LinearLight bool
+	// Sharpen, if set, is applied to every downscaled icon.
+	Sharpen *Sharpen
+	// Pool bounds how many icons are processed at once. If nil, each call
+	// uses its own pool sized to GOMAXPROCS.
+	Pool *Pool
+	// Observer, if set, receives progress events.
+	Observer Observer
+	// Optimize tries lossless colour reductions, filter strategies and
+	// compression levels for every icon, keeping the smallest png.
+	Optimize bool
+	// SizeBudget, if positive, is the largest icns (in bytes) that will be
+	// encoded. Larger results fail with ErrSizeBudget, though the IconSet
+	// methods still return the set so that it can be written anyway.
+	SizeBudget int
+	// BitDepth decides the bits per channel of the icons, for both resizing
+	// and png encoding.
+	BitDepth BitDepth
+	// ColorSpace, if set, is the colour space icons are converted to and
+	// tagged with.
+	ColorSpace ColorSpace
+	// SourceProfile is the colour profile of the source, used when
+	// converting to ColorSpace. Sources without a profile are assumed to be
+	// sRGB. See ExtractProfile.
+	SourceProfile *Profile
+	// Filters transform the source in order, after it is squared and
+	// trimmed, and before it is resized.
+	Filters []Filter
+	// Style, if set, composites the artwork into a platform icon template at
+	// every size.
+	Style *Style
+	// Fit decides how non-square images are squared.
+	Fit FitMode
+	// Crop is the region of the source used when Fit is FitCrop.
+	Crop image.Rectangle
+	// Trim, if set, removes transparent borders and recentres the artwork
+	// after the source is squared.
+	Trim *Trim
+	// Upscale decides what happens to sizes larger than the source.
+	Upscale UpscalePolicy
+	// UpscaleAlgorithm resizes the sizes larger than the source when Upscale
+	// is UpscaleFill. Defaults to Resampler, or Algorithm, if nil.
+	UpscaleAlgorithm Resampler
+	// MinSize is the smallest source accepted when Upscale is UpscaleFail.
+	// Zero means the largest icon size.
+	MinSize uint
 }
 
+// UpscalePolicy decides how icon sizes larger than the source are handled.
+type UpscalePolicy int
+
+// UpscalePolicy constants.
+const (
+	// UpscaleOmit leaves out the sizes larger than the source.
+	UpscaleOmit UpscalePolicy = iota
+	// UpscaleFill upscales the source to fill every size.
+	UpscaleFill
+	// UpscaleFail rejects sources smaller than the minimum size with
+	// ErrImageTooSmall. Sizes larger than an accepted source are omitted.
+	UpscaleFail
+)
+
 // NewEncoder initialises an encoder.
 func NewEncoder(wr io.Writer) *Encoder {
 	return &Encoder{
@@ -26,72 +95,614 @@ func NewEncoder(wr io.Writer) *Encoder {
 // WithAlgorithm applies the interpolation function used to resize the image.
 func (enc *Encoder) WithAlgorithm(a InterpolationFunction) *Encoder {
 	enc.Algorithm = a
+	enc.Resampler = nil
+	return enc
+}
+
+// WithResampler resizes the image with r instead of the interpolation
+// function.
+func (enc *Encoder) WithResampler(r Resampler) *Encoder {
+	enc.Resampler = r
+	return enc
+}
+
+// WithLinearLight toggles resizing in linear light with premultiplied alpha,
+// which avoids dark fringes around transparent edges.
+func (enc *Encoder) WithLinearLight(on bool) *Encoder {
+	enc.LinearLight = on
+	return enc
+}
+
+// WithSharpen applies an unsharp mask to every downscaled icon.
+func (enc *Encoder) WithSharpen(s Sharpen) *Encoder {
+	enc.Sharpen = &s
+	return enc
+}
+
+// WithPixelArt resizes with a PixelArt resampler, upscaling to fill every size
+// since pixel art sources are usually small.
+func (enc *Encoder) WithPixelArt(edgeDirected bool) *Encoder {
+	r := PixelArt{EdgeDirected: edgeDirected}
+	return enc.WithResampler(r).WithUpscale(UpscaleFill, r)
+}
+
+// WithOptimize toggles lossless png optimisation of every icon.
+// Optimising is much slower than the default encoding.
+func (enc *Encoder) WithOptimize(on bool) *Encoder {
+	enc.Optimize = on
+	return enc
+}
+
+// WithSizeBudget fails encoding with ErrSizeBudget if the icns would be
+// larger than budget bytes.
+func (enc *Encoder) WithSizeBudget(budget int) *Encoder {
+	enc.SizeBudget = budget
+	return enc
+}
+
+// WithBitDepth applies the policy for the bits per channel of the icons.
+func (enc *Encoder) WithBitDepth(d BitDepth) *Encoder {
+	enc.BitDepth = d
+	return enc
+}
+
+// WithColorSpace converts icons from the source profile to cs, and tags
+// them as cs.
+func (enc *Encoder) WithColorSpace(cs ColorSpace) *Encoder {
+	enc.ColorSpace = cs
+	return enc
+}
+
+// WithSourceProfile applies the colour profile of the source image.
+func (enc *Encoder) WithSourceProfile(p *Profile) *Encoder {
+	enc.SourceProfile = p
+	return enc
+}
+
+// WithFilters appends filters applied to the source before resizing.
+func (enc *Encoder) WithFilters(f ...Filter) *Encoder {
+	enc.Filters = append(enc.Filters, f...)
+	return enc
+}
+
+// WithBadge overlays b on the source before resizing.
+func (enc *Encoder) WithBadge(b Badge) *Encoder {
+	return enc.WithFilters(b)
+}
+
+// WithStyle composites the artwork into the macOS icon template.
+func (enc *Encoder) WithStyle(s Style) *Encoder {
+	enc.Style = &s
+	return enc
+}
+
+// WithFit applies the mode used to square non-square images.
+func (enc *Encoder) WithFit(mode FitMode) *Encoder {
+	enc.Fit = mode
+	return enc
+}
+
+// WithCrop restricts the source to the pixels inside r.
+func (enc *Encoder) WithCrop(r image.Rectangle) *Encoder {
+	enc.Fit = FitCrop
+	enc.Crop = r
+	return enc
+}
+
+// WithTrim removes transparent borders from the source and recentres the
+// artwork with the given margin.
+func (enc *Encoder) WithTrim(t Trim) *Encoder {
+	enc.Trim = &t
+	return enc
+}
+
+// WithUpscale applies the policy for sizes larger than the source, and the
+// resampler used if they are upscaled.
+func (enc *Encoder) WithUpscale(p UpscalePolicy, a Resampler) *Encoder {
+	enc.Upscale = p
+	enc.UpscaleAlgorithm = a
+	return enc
+}
+
+// WithMinSize rejects sources smaller than size (in px).
+func (enc *Encoder) WithMinSize(size uint) *Encoder {
+	enc.Upscale = UpscaleFail
+	enc.MinSize = size
+	return enc
+}
+
+// WithConcurrency limits the number of icons processed at once to n.
+func (enc *Encoder) WithConcurrency(n int) *Encoder {
+	enc.Pool = NewPool(n)
+	return enc
+}
+
+// WithPool processes icons using a pool that may be shared with other
+// Encoders.
+func (enc *Encoder) WithPool(p *Pool) *Encoder {
+	enc.Pool = p
+	return enc
+}
+
+// WithObserver reports progress events to o.
+func (enc *Encoder) WithObserver(o Observer) *Encoder {
+	enc.Observer = o
 	return enc
 }
 
 // Encode icns with the given configuration.
 func (enc *Encoder) Encode(img image.Image) error {
+	return enc.EncodeContext(context.Background(), img)
+}
+
+// EncodeContext encodes icns with the given configuration, stopping early
+// with ctx.Err() if ctx is done.
+func (enc *Encoder) EncodeContext(ctx context.Context, img image.Image) error {
 	if enc.Wr == nil {
 		return errors.New("cannot write to nil writer")
 	}
+	iconset, err := enc.IconSetContext(ctx, img)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := iconset.WriteTo(enc.Wr); err != nil {
+		return err
+	}
+	return nil
+}
+
+// IconSet creates the IconSet that Encode would write for img.
+func (enc *Encoder) IconSet(img image.Image) (*IconSet, error) {
+	return enc.IconSetContext(context.Background(), img)
+}
+
+// IconSetContext creates the IconSet that Encode would write for img,
+// stopping early with ctx.Err() if ctx is done.
+func (enc *Encoder) IconSetContext(ctx context.Context, img image.Image) (*IconSet, error) {
 	if img == nil {
-		return errors.New("cannot encode nil image")
+		return nil, errors.New("cannot encode nil image")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	iconset, err := NewIconSet(img, enc.Algorithm)
+	start := time.Now()
+	targets, render, err := enc.imageRenderer(img, sizes)
+	if err != nil {
+		return nil, err
+	}
+	return enc.iconSet(ctx, start, targets, render)
+}
+
+// Images renders img at each size (in px) through the same pipeline as the
+// icons of an IconSet, for other icon formats. Images are returned largest
+// first, leaving out sizes larger than the source unless Upscale is
+// UpscaleFill.
+func (enc *Encoder) Images(img image.Image, sizes []uint) ([]image.Image, error) {
+	return enc.ImagesContext(context.Background(), img, sizes)
+}
+
+// ImagesContext is Images, stopping early with ctx.Err() if ctx is done.
+func (enc *Encoder) ImagesContext(ctx context.Context, img image.Image, sizes []uint) ([]image.Image, error) {
+	if img == nil {
+		return nil, errors.New("cannot encode nil image")
+	}
+	if len(sizes) == 0 {
+		return nil, errors.New("no sizes to render")
+	}
+	targets, render, err := enc.imageRenderer(img, descending(sizes))
+	if err != nil {
+		return nil, err
+	}
+	return enc.images(ctx, targets, render)
+}
+
+// imageRenderer prepares img, and returns the sizes (largest first) it is
+// rendered at and how each is rendered.
+func (enc *Encoder) imageRenderer(img image.Image, sizes []uint) ([]uint, renderFunc, error) {
+	img, err := enc.prepare(img)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, err := enc.targets(img, sizes)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		source   = biggestSide(img)
+		down, up = enc.resamplers()
+		// Convert once up front so that every resize reads the same, cheap
+		// to access pixel format.
+		src, sixteen = enc.convert(img)
+	)
+	return targets, func(inner uint) (image.Image, bool, bool, error) {
+		interp, upscaled := down, inner > source
+		if upscaled {
+			interp = up
+		}
+		iconImg := interp.Resize(src, inner, inner)
+		if enc.Sharpen != nil {
+			iconImg = enc.Sharpen.apply(iconImg, float64(source)/float64(inner))
+		}
+		return iconImg, sixteen, upscaled, nil
+	}, nil
+}
+
+// targets returns the sizes, largest first, that img is rendered at
+// according to Upscale.
+func (enc *Encoder) targets(img image.Image, sizes []uint) ([]uint, error) {
+	var (
+		source   = biggestSide(img)
+		smallest = sizes[len(sizes)-1]
+	)
+	if source < smallest {
+		return nil, ErrImageTooSmall{image: img, need: int(smallest)}
+	}
+	switch enc.Upscale {
+	case UpscaleFill:
+		return sizes, nil
+	case UpscaleFail:
+		min := enc.MinSize
+		if min == 0 {
+			min = sizes[0]
+		}
+		if source < min {
+			return nil, ErrImageTooSmall{image: img, need: int(min)}
+		}
+	}
+	var targets []uint
+	for _, size := range sizes {
+		if size <= source {
+			targets = append(targets, size)
+		}
+	}
+	return targets, nil
+}
+
+// descending returns a copy of sizes, largest first, without duplicates.
+func descending(sizes []uint) []uint {
+	sorted := append([]uint(nil), sizes...)
+	sort.Slice(sorted, func(ii, jj int) bool {
+		return sorted[ii] > sorted[jj]
+	})
+	unique := sorted[:0]
+	for ii, size := range sorted {
+		if ii == 0 || size != sorted[ii-1] {
+			unique = append(unique, size)
+		}
+	}
+	return unique
+}
+
+// EncodeSource encodes icns from a source rendered at each icon size.
+func (enc *Encoder) EncodeSource(p SourceProvider) error {
+	return enc.EncodeSourceContext(context.Background(), p)
+}
+
+// EncodeSourceContext encodes icns from a source rendered at each icon size,
+// stopping early with ctx.Err() if ctx is done.
+func (enc *Encoder) EncodeSourceContext(ctx context.Context, p SourceProvider) error {
+	if enc.Wr == nil {
+		return errors.New("cannot write to nil writer")
+	}
+	iconset, err := enc.IconSetSourceContext(ctx, p)
 	if err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if _, err := iconset.WriteTo(enc.Wr); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Encode writes img to wr in ICNS format.
-// img is assumed to be a rectangle; non-square dimensions will be squared
-// without preserving the aspect ratio.
-// Uses nearest neighbor as interpolation algorithm.
-func Encode(wr io.Writer, img image.Image) error {
-	return NewEncoder(wr).Encode(img)
+// IconSetSource creates the IconSet that EncodeSource would write for p.
+func (enc *Encoder) IconSetSource(p SourceProvider) (*IconSet, error) {
+	return enc.IconSetSourceContext(context.Background(), p)
 }
 
-// NewIconSet uses the source image to create an IconSet.
-// If width != height, the image will be resized using the largest side without
-// preserving the aspect ratio.
-func NewIconSet(img image.Image, interp InterpolationFunction) (*IconSet, error) {
-	biggest := findNearestSize(img)
-	if biggest == 0 {
-		return nil, ErrImageTooSmall{image: img, need: 16}
-	}
-	icons := make([]*Icon, len(osTypes))
-	work := sync.WaitGroup{}
-	var iconIdx int
-	for _, size := range sizesFrom(biggest) {
-		osTypes, ok := getTypesFromSize(size)
+// IconSetSourceContext creates the IconSet that EncodeSource would write for
+// p, stopping early with ctx.Err() if ctx is done.
+// Every size is rendered, so nothing is upscaled or sharpened. The renders go
+// through the same fit, trim, filters and colour conversion as an image, and
+// are resized with Algorithm if that changes their size.
+func (enc *Encoder) IconSetSourceContext(ctx context.Context, p SourceProvider) (*IconSet, error) {
+	if p == nil {
+		return nil, errors.New("cannot encode nil source")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return enc.iconSet(ctx, time.Now(), sizes, enc.sourceRenderer(p))
+}
+
+// ImagesSource renders p at each size (in px) as IconSetSource would, for
+// other icon formats. Images are returned largest first.
+func (enc *Encoder) ImagesSource(p SourceProvider, sizes []uint) ([]image.Image, error) {
+	return enc.ImagesSourceContext(context.Background(), p, sizes)
+}
+
+// ImagesSourceContext is ImagesSource, stopping early with ctx.Err() if ctx
+// is done.
+func (enc *Encoder) ImagesSourceContext(ctx context.Context, p SourceProvider, sizes []uint) ([]image.Image, error) {
+	if p == nil {
+		return nil, errors.New("cannot encode nil source")
+	}
+	if len(sizes) == 0 {
+		return nil, errors.New("no sizes to render")
+	}
+	return enc.images(ctx, descending(sizes), enc.sourceRenderer(p))
+}
+
+// sourceRenderer renders p at each size, through the same fit, trim, filters
+// and colour conversion as an image.
+func (enc *Encoder) sourceRenderer(p SourceProvider) renderFunc {
+	down, _ := enc.resamplers()
+	return func(inner uint) (image.Image, bool, bool, error) {
+		img, err := p.Image(inner)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if img, err = enc.prepare(img); err != nil {
+			return nil, false, false, err
+		}
+		src, sixteen := enc.convert(img)
+		if b := src.Bounds(); b.Dx() != int(inner) || b.Dy() != int(inner) {
+			src = down.Resize(src, inner, inner)
+		}
+		return src, sixteen, false, nil
+	}
+}
+
+// prepare squares, trims and filters the source.
+func (enc *Encoder) prepare(img image.Image) (image.Image, error) {
+	img = fit(img, enc.Fit, enc.Crop)
+	if enc.Trim != nil {
+		trimmed, err := enc.Trim.apply(img)
+		if err != nil {
+			return nil, err
+		}
+		img = trimmed
+	}
+	return applyFilters(img, enc.Filters)
+}
+
+// resamplers returns the resamplers for downscaling and upscaling, with
+// defaults applied.
+func (enc *Encoder) resamplers() (down, up Resampler) {
+	down, up = enc.Resampler, enc.UpscaleAlgorithm
+	if down == nil {
+		down = Interpolate(enc.Algorithm)
+	}
+	if up == nil {
+		up = down
+	}
+	if enc.LinearLight {
+		down, up = Linear(down), Linear(up)
+	}
+	return down, up
+}
+
+// convert returns img at the bit depth chosen for it, in the target colour
+// space, and whether that depth is 16 bits.
+func (enc *Encoder) convert(img image.Image) (image.Image, bool) {
+	sixteen := enc.BitDepth.sixteenBit(img)
+	src := toDepth(img, sixteen)
+	if target := enc.ColorSpace.profile(); target != nil {
+		source := enc.SourceProfile
+		if source == nil {
+			source = sRGBProfile
+		}
+		if source != target {
+			src = convertColor(src, source, target, sixteen)
+		}
+	}
+	return src, sixteen
+}
+
+// renderFunc returns the artwork for an icon, inner px square, whether it
+// should be encoded with 16 bits per channel and whether it was upscaled.
+type renderFunc func(inner uint) (img image.Image, sixteen, upscaled bool, err error)
+
+// iconSet renders, composites and encodes every target size.
+func (enc *Encoder) iconSet(ctx context.Context, start time.Time, targets []uint, render renderFunc) (*IconSet, error) {
+	encode := encodeImage
+	if enc.Optimize {
+		encode = optimizePNG
+	}
+	var (
+		pool     = enc.pool()
+		icons    = make([]*Icon, len(osTypes))
+		errs     = make([]error, len(targets))
+		timings  = make([][2]time.Duration, len(targets))
+		work     = sync.WaitGroup{}
+		progress = &notifier{observer: enc.Observer}
+		iconIdx  int
+	)
+	// Each distinct size is rendered and encoded once, and shared by every
+	// type of that size.
+	for ii, size := range targets {
+		types, ok := getTypesFromSize(size)
 		if !ok {
 			continue
 		}
-		size := size
-		for _, osType := range osTypes {
-			work.Add(1)
-			go func(iconIdx int, osType OsType, size uint) {
-				iconImg := resize.Resize(size, size, img, interp)
-				icons[iconIdx] = &Icon{
-					Type:  osType,
-					Image: iconImg,
-				}
-				work.Done()
-			}(iconIdx, osType, size)
-			iconIdx += 1
+		if err := pool.acquire(ctx); err != nil {
+			break
 		}
+		work.Add(1)
+		go func(ii, iconIdx int, types []OsType, size uint) {
+			defer work.Done()
+			defer pool.release()
+			if ctx.Err() != nil {
+				return
+			}
+			for _, osType := range types {
+				progress.notify(Event{Kind: EntryStarted, Type: osType})
+			}
+			began := time.Now()
+			iconImg, upscaled, err := enc.renderIcon(render, size)
+			if err != nil {
+				errs[ii] = err
+				return
+			}
+			resized := time.Now()
+			if ctx.Err() != nil {
+				return
+			}
+			data, err := encode(iconImg)
+			if err == nil {
+				data, err = tagColorSpace(data, enc.ColorSpace)
+			}
+			if err != nil {
+				errs[ii] = fmt.Errorf("encoding %dpx icon: %w", size, err)
+				return
+			}
+			timings[ii] = [2]time.Duration{resized.Sub(began), time.Since(resized)}
+			for jj, osType := range types {
+				icons[iconIdx+jj] = &Icon{
+					Type:     osType,
+					Image:    iconImg,
+					Upscaled: upscaled,
+					data:     data,
+				}
+				progress.notify(Event{
+					Kind:   EntryFinished,
+					Type:   osType,
+					Resize: timings[ii][0],
+					Bytes:  len(data),
+				})
+			}
+		}(ii, iconIdx, types, size)
+		iconIdx += len(types)
 	}
 	work.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
 	iconSet := &IconSet{
 		Icons: icons,
 	}
+	for _, icon := range icons {
+		if icon == nil {
+			continue
+		}
+		iconSet.Stats.add(icon.Type, len(icon.data))
+		if icon.Upscaled {
+			iconSet.Stats.Upscaled++
+		}
+	}
+	for _, t := range timings {
+		iconSet.Stats.Resize += t[0]
+		iconSet.Stats.Encode += t[1]
+	}
+	iconSet.Stats.Elapsed = time.Since(start)
+	progress.notify(Event{Kind: Finished, Stats: &iconSet.Stats})
+	if enc.SizeBudget > 0 {
+		if err := iconSet.checkBudget(enc.SizeBudget); err != nil {
+			return iconSet, err
+		}
+	}
 	return iconSet, nil
 }
 
+// renderIcon renders a size px icon, composited into Style if set.
+func (enc *Encoder) renderIcon(render renderFunc, size uint) (image.Image, bool, error) {
+	// The artwork may only fill part of the icon.
+	inner := size
+	if enc.Style != nil {
+		inner = enc.Style.inner(size)
+	}
+	img, sixteen, upscaled, err := render(inner)
+	if err != nil {
+		return nil, false, fmt.Errorf("rendering %dpx icon: %w", size, err)
+	}
+	if enc.Style != nil {
+		img = enc.Style.apply(img, size)
+	}
+	return toDepth(img, sixteen), upscaled, nil
+}
+
+// images renders every target size, sharing the pool like iconSet.
+func (enc *Encoder) images(ctx context.Context, targets []uint, render renderFunc) ([]image.Image, error) {
+	var (
+		pool   = enc.pool()
+		images = make([]image.Image, len(targets))
+		errs   = make([]error, len(targets))
+		work   = sync.WaitGroup{}
+	)
+	for ii, size := range targets {
+		if err := pool.acquire(ctx); err != nil {
+			break
+		}
+		work.Add(1)
+		go func(ii int, size uint) {
+			defer work.Done()
+			defer pool.release()
+			if ctx.Err() != nil {
+				return
+			}
+			images[ii], _, errs[ii] = enc.renderIcon(render, size)
+		}(ii, size)
+	}
+	work.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// pool returns the encoder's pool, or a new one sized to GOMAXPROCS.
+func (enc *Encoder) pool() *Pool {
+	if enc.Pool == nil {
+		return NewPool(runtime.GOMAXPROCS(0))
+	}
+	return enc.Pool
+}
+
+// Encode writes img to wr in ICNS format, using the defaults of NewEncoder:
+// non-square images are stretched square without preserving the aspect
+// ratio, resized with MitchellNetravali, and sizes larger than img are left
+// out. Use an Encoder to change any of these.
+func Encode(wr io.Writer, img image.Image) error {
+	return NewEncoder(wr).Encode(img)
+}
+
+// NewIconSet uses the source image to create an IconSet.
+// If width != height, the image will be resized using the largest side without
+// preserving the aspect ratio. Use an Encoder with a FitMode to preserve it.
+// Use NewIconSetResampler to resize with something other than an
+// InterpolationFunction.
+func NewIconSet(img image.Image, interp InterpolationFunction) (*IconSet, error) {
+	return NewIconSetContext(context.Background(), img, interp)
+}
+
+// NewIconSetContext is NewIconSet, stopping early with ctx.Err() if ctx is
+// done.
+func NewIconSetContext(ctx context.Context, img image.Image, interp InterpolationFunction) (*IconSet, error) {
+	enc := &Encoder{Algorithm: interp}
+	return enc.IconSetContext(ctx, img)
+}
+
+// NewIconSetResampler is NewIconSet, resizing with r.
+func NewIconSetResampler(img image.Image, r Resampler) (*IconSet, error) {
+	enc := &Encoder{Resampler: r}
+	return enc.IconSet(img)
+}
+
 // Big-endian.
 // https://golang.org/src/image/png/writer.go
 func writeUint32(b []uint8, u uint32) {
@@ -123,14 +734,12 @@ func findNearestSize(img image.Image) uint {
 }
 
 func biggestSide(img image.Image) uint {
-	var size uint
 	b := img.Bounds()
-	w, h := uint(b.Max.X), uint(b.Max.Y)
-	size = w
-	if h > size {
-		size = h
+	size := b.Dx()
+	if b.Dy() > size {
+		size = b.Dy()
 	}
-	return size
+	return uint(size)
 }
 
 // sizesFrom returns a slice containing the sizes less than and including max.
@@ -172,8 +781,27 @@ func getTypesFromSize(size uint) ([]OsType, bool) {
 	return retOsTypes, len(retOsTypes) != 0
 }
 
+// legacyTypes are read from icns files and iconsets, but not encoded.
+var legacyTypes = []OsType{
+	{ID: "icp5", Size: uint(32)},
+	{ID: "icp4", Size: uint(16)},
+}
+
+// knownTypes are every type that can be read.
+var knownTypes = append(append([]OsType{}, osTypes...), legacyTypes...)
+
+// isLegacy reports whether t is only read, never encoded.
+func isLegacy(t OsType) bool {
+	for _, l := range legacyTypes {
+		if l.ID == t.ID {
+			return true
+		}
+	}
+	return false
+}
+
 func getTypeFromID(ID string) (OsType, bool) {
-	for _, t := range osTypes {
+	for _, t := range knownTypes {
 		if t.ID == ID {
 			return t, true
 		}
@@ -9,7 +9,10 @@ import (
 	"io"
 	"io/ioutil"
 	"reflect"
+	"sync"
 	"testing"
+
+	"github.com/nfnt/resize"
 )
 
 // TestDecode relies on Encode being correct.
@@ -145,6 +148,90 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+func TestUpscale(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc string
+		enc  *Encoder
+		img  image.Image
+
+		wantErr      bool
+		wantTypes    int
+		wantUpscaled []string
+	}{
+		{
+			"omit larger sizes",
+			NewEncoder(nil).WithAlgorithm(NearestNeighbor),
+			rect(0, 0, 300, 300),
+			false,
+			5,
+			nil,
+		},
+		{
+			"fill every size",
+			NewEncoder(nil).WithUpscale(UpscaleFill, Interpolate(NearestNeighbor)),
+			rect(0, 0, 300, 300),
+			false,
+			len(osTypes),
+			[]string{"ic10", "ic14", "ic09"},
+		},
+		{
+			"fail below default minimum",
+			NewEncoder(nil).WithUpscale(UpscaleFail, Interpolate(NearestNeighbor)),
+			rect(0, 0, 300, 300),
+			true,
+			0,
+			nil,
+		},
+		{
+			"fail below configured minimum",
+			NewEncoder(nil).WithMinSize(512),
+			rect(0, 0, 300, 300),
+			true,
+			0,
+			nil,
+		},
+		{
+			"pass configured minimum",
+			NewEncoder(nil).WithAlgorithm(NearestNeighbor).WithMinSize(256),
+			rect(0, 0, 300, 300),
+			false,
+			5,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			iconset, err := tt.enc.IconSet(tt.img)
+			if tt.wantErr {
+				if _, ok := err.(ErrImageTooSmall); !ok {
+					st.Fatalf("want ErrImageTooSmall, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			var got int
+			for _, icon := range iconset.Icons {
+				if icon != nil {
+					got++
+				}
+			}
+			if got != tt.wantTypes {
+				st.Errorf("icons: want=%d, got=%d", tt.wantTypes, got)
+			}
+			var upscaled []string
+			for _, t := range iconset.Upscaled() {
+				upscaled = append(upscaled, t.ID)
+			}
+			if !reflect.DeepEqual(upscaled, tt.wantUpscaled) {
+				st.Errorf("upscaled: want=%v, got=%v", tt.wantUpscaled, upscaled)
+			}
+		})
+	}
+}
+
 func TestSizesFromMax(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -220,6 +307,11 @@ func TestBiggestSide(t *testing.T) {
 			rect(0, 0, 10, 0),
 			10,
 		},
+		{
+			"not at origin point",
+			rect(10, 20, 50, 50),
+			40,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.desc, func(st *testing.T) {
@@ -231,6 +323,90 @@ func TestBiggestSide(t *testing.T) {
 	}
 }
 
+func TestFit(t *testing.T) {
+	t.Parallel()
+	opaque := image.NewNRGBA(image.Rect(10, 10, 110, 60))
+	for ii := range opaque.Pix {
+		opaque.Pix[ii] = 0xff
+	}
+	tests := []struct {
+		desc string
+		img  image.Image
+		mode FitMode
+		crop image.Rectangle
+
+		want image.Rectangle
+		// transparent is a point expected to be padding.
+		transparent *image.Point
+	}{
+		{
+			"stretch is a no-op",
+			opaque,
+			FitStretch,
+			image.Rectangle{},
+			image.Rect(10, 10, 110, 60),
+			nil,
+		},
+		{
+			"contain pads shorter side",
+			opaque,
+			FitContain,
+			image.Rectangle{},
+			image.Rect(0, 0, 100, 100),
+			&image.Point{50, 10},
+		},
+		{
+			"cover crops longer side around centre",
+			opaque,
+			FitCover,
+			image.Rectangle{},
+			image.Rect(35, 10, 85, 60),
+			nil,
+		},
+		{
+			"crop",
+			opaque,
+			FitCrop,
+			image.Rect(20, 20, 40, 40),
+			image.Rect(20, 20, 40, 40),
+			nil,
+		},
+		{
+			"crop clipped to bounds",
+			opaque,
+			FitCrop,
+			image.Rect(0, 0, 40, 40),
+			image.Rect(10, 10, 40, 40),
+			nil,
+		},
+		{
+			"cover without SubImage",
+			rect(0, 0, 20, 40),
+			FitCover,
+			image.Rectangle{},
+			image.Rect(0, 0, 20, 20),
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			got := fit(tt.img, tt.mode, tt.crop)
+			if got.Bounds() != tt.want {
+				st.Fatalf("bounds: want=%v, got=%v", tt.want, got.Bounds())
+			}
+			if tt.transparent != nil {
+				if _, _, _, a := got.At(tt.transparent.X, tt.transparent.Y).RGBA(); a != 0 {
+					st.Errorf("want transparent padding at %v, got alpha=%d", tt.transparent, a)
+				}
+				c := got.Bounds().Size().Div(2)
+				if _, _, _, a := got.At(c.X, c.Y).RGBA(); a == 0 {
+					st.Errorf("want opaque content at %v", c)
+				}
+			}
+		})
+	}
+}
+
 func TestFindNearestSize(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -370,3 +546,172 @@ func _decode(r io.Reader) image.Image {
 	}
 	return m
 }
+
+func TestImages(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc  string
+		enc   *Encoder
+		sizes []uint
+		want  []int
+		err   bool
+	}{
+		{
+			"largest first, without duplicates",
+			NewEncoder(nil),
+			[]uint{16, 48, 24, 48},
+			[]int{48, 24, 16},
+			false,
+		},
+		{
+			"larger than source omitted",
+			NewEncoder(nil),
+			[]uint{256, 64, 16},
+			[]int{64, 16},
+			false,
+		},
+		{
+			"upscale fill",
+			NewEncoder(nil).WithUpscale(UpscaleFill, nil),
+			[]uint{256, 64},
+			[]int{256, 64},
+			false,
+		},
+		{
+			"upscale fail",
+			NewEncoder(nil).WithUpscale(UpscaleFail, nil),
+			[]uint{256, 64},
+			nil,
+			true,
+		},
+		{
+			"style",
+			NewEncoder(nil).WithStyle(Style{}),
+			[]uint{48},
+			[]int{48},
+			false,
+		},
+		{
+			"no sizes",
+			NewEncoder(nil),
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			images, err := tt.enc.Images(opaqueImage(100), tt.sizes)
+			if tt.err {
+				if err == nil {
+					st.Fatalf("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			var got []int
+			for _, img := range images {
+				if b := img.Bounds(); b.Dx() != b.Dy() {
+					st.Errorf("want square image, got %v", b)
+				}
+				got = append(got, img.Bounds().Dx())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				st.Errorf("want sizes %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewPNGIcon(t *testing.T) {
+	t.Parallel()
+	encode := func(size int) []byte {
+		buf := bytes.NewBuffer(nil)
+		if err := png.Encode(buf, opaqueImage(size)); err != nil {
+			t.Fatalf("encoding: %v", err)
+		}
+		return buf.Bytes()
+	}
+	tests := []struct {
+		desc string
+		data []byte
+		err  bool
+	}{
+		{"matching size", encode(128), false},
+		{"wrong size", encode(64), true},
+		{"not png", []byte("not a png"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			icon, err := NewPNGIcon(osTypeFromID("ic07"), tt.data)
+			if tt.err {
+				if err == nil {
+					st.Fatalf("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			buf := bytes.NewBuffer(nil)
+			if _, err := icon.WriteTo(buf); err != nil {
+				st.Fatalf("writing: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes()[8:], tt.data) {
+				st.Errorf("want data written unchanged")
+			}
+		})
+	}
+}
+
+func TestIconSetSharesSizes(t *testing.T) {
+	t.Parallel()
+	r := &countingResampler{}
+	iconset, err := NewEncoder(nil).WithResampler(r).IconSet(rect(0, 0, 1024, 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := len(sizes) - 1; int(r.calls) != want {
+		t.Errorf("resizes: want=%d, got=%d", want, r.calls)
+	}
+	bySize := map[uint][]byte{}
+	for _, icon := range iconset.Icons {
+		data, ok := bySize[icon.Type.Size]
+		if !ok {
+			bySize[icon.Type.Size] = icon.data
+			continue
+		}
+		if &data[0] != &icon.data[0] {
+			t.Errorf("%s: want encoded data shared with other %dpx icons", icon.Type.ID, icon.Type.Size)
+		}
+	}
+}
+
+// legacyIconSet is the original NewIconSet, which resized the full source and
+// encoded a png for every type, kept to benchmark against.
+func legacyIconSet(img image.Image, interp resize.InterpolationFunction) *IconSet {
+	icons := make([]*Icon, len(osTypes))
+	work := sync.WaitGroup{}
+	var iconIdx int
+	for _, size := range sizesFrom(findNearestSize(img)) {
+		osTypes, ok := getTypesFromSize(size)
+		if !ok {
+			continue
+		}
+		for _, osType := range osTypes {
+			work.Add(1)
+			go func(iconIdx int, osType OsType, size uint) {
+				icons[iconIdx] = &Icon{
+					Type:  osType,
+					Image: resize.Resize(size, size, img, interp),
+				}
+				work.Done()
+			}(iconIdx, osType, size)
+			iconIdx += 1
+		}
+	}
+	work.Wait()
+	return &IconSet{Icons: icons}
+}
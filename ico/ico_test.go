@@ -0,0 +1,421 @@
+package ico
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/jackmordaunt/icns/v3"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	src := image.NewNRGBA(image.Rect(0, 0, 512, 512))
+	for y := 0; y < 512; y++ {
+		for x := 0; x < 512; x++ {
+			// The left half is transparent.
+			var a uint8
+			if x >= 256 {
+				a = 0xff
+			}
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 0x80, A: a})
+		}
+	}
+	tests := []struct {
+		desc   string
+		format Format
+		// png lists which entries, largest first, are stored as png.
+		png []bool
+	}{
+		{"auto", FormatAuto, []bool{true, false, false, false, false, false}},
+		{"png", FormatPNG, []bool{true, true, true, true, true, true}},
+		{"bmp", FormatBMP, []bool{false, false, false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			var (
+				buf      = bytes.NewBuffer(nil)
+				pipeline = icns.NewEncoder(nil).WithAlgorithm(icns.NearestNeighbor)
+			)
+			if err := NewEncoder(buf).WithPipeline(pipeline).WithFormat(tt.format).Encode(src); err != nil {
+				st.Fatalf("encoding: %v", err)
+			}
+			entries, err := readEntries(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				st.Fatalf("reading entries: %v", err)
+			}
+			for ii, e := range entries {
+				if got := bytes.HasPrefix(e.data, pngSignature); got != tt.png[ii] {
+					st.Errorf("%dpx: want png %t, got %t", e.width, tt.png[ii], got)
+				}
+			}
+			want, err := pipeline.Images(src, Sizes)
+			if err != nil {
+				st.Fatalf("rendering: %v", err)
+			}
+			got, err := DecodeAll(buf)
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			if len(got) != len(want) {
+				st.Fatalf("want %d images, got %d", len(want), len(got))
+			}
+			for ii := range want {
+				if !sameImage(got[ii], want[ii]) {
+					st.Errorf("%dpx: decoded image differs", want[ii].Bounds().Dx())
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderSizes(t *testing.T) {
+	t.Parallel()
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	tests := []struct {
+		desc  string
+		sizes []uint
+		want  []int
+		err   bool
+	}{
+		{"larger than source omitted", nil, []int{32, 24, 16}, false},
+		{"custom", []uint{16, 20}, []int{20, 16}, false},
+		{"too large", []uint{512}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			err := NewEncoder(buf).WithSizes(tt.sizes...).Encode(src)
+			if tt.err {
+				if err == nil {
+					st.Fatalf("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			images, err := DecodeAll(buf)
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			var got []int
+			for _, img := range images {
+				got = append(got, img.Bounds().Dx())
+			}
+			if len(got) != len(tt.want) {
+				st.Fatalf("want sizes %v, got %v", tt.want, got)
+			}
+			for ii := range got {
+				if got[ii] != tt.want[ii] {
+					st.Fatalf("want sizes %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeBMP(t *testing.T) {
+	t.Parallel()
+	var (
+		red   = color.NRGBA{R: 0xff, A: 0xff}
+		green = color.NRGBA{G: 0xff, A: 0xff}
+		blue  = color.NRGBA{B: 0xff, A: 0xff}
+		clear = color.NRGBA{}
+	)
+	// The entries are 2x2: the colours are given top row first, and the
+	// bottom right pixel is masked.
+	tests := []struct {
+		desc     string
+		bitCount int
+		palette  []color.NRGBA
+		pixels   []uint32
+		want     []color.NRGBA
+	}{
+		{
+			"1 bit",
+			1,
+			[]color.NRGBA{red, blue},
+			[]uint32{0, 1, 1, 0},
+			[]color.NRGBA{red, blue, blue, clear},
+		},
+		{
+			"4 bit",
+			4,
+			[]color.NRGBA{red, green, blue},
+			[]uint32{0, 1, 2, 2},
+			[]color.NRGBA{red, green, blue, clear},
+		},
+		{
+			"8 bit",
+			8,
+			[]color.NRGBA{red, green, blue},
+			[]uint32{2, 1, 0, 0},
+			[]color.NRGBA{blue, green, red, clear},
+		},
+		{
+			"24 bit",
+			24,
+			nil,
+			[]uint32{0xff0000, 0x00ff00, 0x0000ff, 0xffffff},
+			[]color.NRGBA{red, green, blue, clear},
+		},
+		{
+			"32 bit alpha",
+			32,
+			nil,
+			[]uint32{0xffff0000, 0x8000ff00, 0xff0000ff, 0xffffffff},
+			[]color.NRGBA{red, {G: 0xff, A: 0x80}, blue, {0xff, 0xff, 0xff, 0xff}},
+		},
+		{
+			"32 bit unused alpha",
+			32,
+			nil,
+			[]uint32{0xff0000, 0x00ff00, 0x0000ff, 0xffffff},
+			[]color.NRGBA{red, green, blue, clear},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			data := icoFile(bmpEntry(tt.bitCount, tt.palette, tt.pixels))
+			img, format, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			if format != "ico" {
+				st.Errorf("want format ico, got %q", format)
+			}
+			for ii, want := range tt.want {
+				if got := color.NRGBAModel.Convert(img.At(ii%2, ii/2)); got != want {
+					st.Errorf("pixel %d: want %v, got %v", ii, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestIconSet(t *testing.T) {
+	t.Parallel()
+	var (
+		large = image.NewNRGBA(image.Rect(0, 0, 256, 256))
+		// The small image is 16 bit, which BitDepth8 must not keep.
+		small = image.NewNRGBA64(image.Rect(0, 0, 128, 128))
+		red   = color.NRGBA{R: 0xff, A: 0xff}
+	)
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			large.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+	// The small image is drawn differently, as a simplified icon would be.
+	draw.Draw(small, small.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+	var entries [][]byte
+	for _, img := range []image.Image{large, small} {
+		buf := bytes.NewBuffer(nil)
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatalf("encoding entry: %v", err)
+		}
+		entries = append(entries, buf.Bytes())
+	}
+	data := icoFile(entries...)
+	tests := []struct {
+		desc     string
+		pipeline *icns.Encoder
+		// kept reports whether the 128px png entry is used unchanged.
+		kept bool
+	}{
+		{"default", nil, true},
+		{"resize only", icns.NewEncoder(nil).WithAlgorithm(icns.NearestNeighbor), true},
+		{"filtered", icns.NewEncoder(nil).WithFilters(icns.Grayscale{Amount: 1}), false},
+		{"8 bit", icns.NewEncoder(nil).WithBitDepth(icns.BitDepth8), false},
+		{"size budget", icns.NewEncoder(nil).WithSizeBudget(1 << 30), false},
+		{"observed", icns.NewEncoder(nil).WithObserver(func(icns.Event) {}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			set, err := IconSet(bytes.NewReader(data), tt.pipeline)
+			if err != nil {
+				st.Fatalf("creating icon set: %v", err)
+			}
+			buf := bytes.NewBuffer(nil)
+			if _, err := set.WriteTo(buf); err != nil {
+				st.Fatalf("writing: %v", err)
+			}
+			if got := bytes.Contains(buf.Bytes(), entries[1]); got != tt.kept {
+				st.Errorf("want 128px entry kept %t, got %t", tt.kept, got)
+			}
+			decoded, err := icns.NewDecoder(buf).DecodeIconSet()
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			var sizes []uint
+			for _, icon := range decoded.Icons {
+				sizes = append(sizes, icon.Type.Size)
+				if icon.Type.Size != 128 {
+					continue
+				}
+				if got := color.NRGBAModel.Convert(icon.Image.At(0, 0)) == red; got != tt.kept {
+					st.Errorf("%s: want the small image %t, got %t", icon.Type.ID, tt.kept, got)
+				}
+			}
+			// Sizes larger than the largest image are left out.
+			if want := []uint{256, 256, 128, 64, 32}; !reflect.DeepEqual(sizes, want) {
+				st.Errorf("want sizes %v, got %v", want, sizes)
+			}
+		})
+	}
+}
+
+func TestIconSetNonSquare(t *testing.T) {
+	t.Parallel()
+	var (
+		wide  = image.NewNRGBA(image.Rect(0, 0, 128, 64))
+		small = image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		red   = color.NRGBA{R: 0xff, A: 0xff}
+	)
+	draw.Draw(wide, wide.Rect, image.NewUniform(color.NRGBA{B: 0xff, A: 0xff}), image.Point{}, draw.Src)
+	draw.Draw(small, small.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+	var entries [][]byte
+	for _, img := range []image.Image{wide, small} {
+		buf := bytes.NewBuffer(nil)
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatalf("encoding entry: %v", err)
+		}
+		entries = append(entries, buf.Bytes())
+	}
+	data := icoFile(entries...)
+	tests := []struct {
+		desc     string
+		pipeline *icns.Encoder
+		// kept reports whether the 32px png entry is used unchanged.
+		kept bool
+	}{
+		{"stretch", nil, true},
+		{"contain", icns.NewEncoder(nil).WithFit(icns.FitContain), false},
+		{"cover", icns.NewEncoder(nil).WithFit(icns.FitCover), false},
+		{"crop", icns.NewEncoder(nil).WithCrop(image.Rect(0, 0, 64, 64)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			set, err := IconSet(bytes.NewReader(data), tt.pipeline)
+			if err != nil {
+				st.Fatalf("creating icon set: %v", err)
+			}
+			buf := bytes.NewBuffer(nil)
+			if _, err := set.WriteTo(buf); err != nil {
+				st.Fatalf("writing: %v", err)
+			}
+			if got := bytes.Contains(buf.Bytes(), entries[1]); got != tt.kept {
+				st.Errorf("want 32px entry kept %t, got %t", tt.kept, got)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	t.Parallel()
+	for _, data := range [][]byte{
+		nil,
+		{0, 0, 2, 0, 1, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 1, 0, 1, 0, 16, 16},
+	} {
+		if _, err := Decode(bytes.NewReader(data)); err == nil {
+			t.Errorf("%v: want error, got nil", data)
+		}
+	}
+}
+
+// bmpEntry returns a 2x2 bmp entry of the given depth. pixels are palette
+// indices, or 0xAARRGGBB colours for 24 and 32 bits, top row first. The AND
+// mask covers the bottom right pixel.
+func bmpEntry(bitCount int, palette []color.NRGBA, pixels []uint32) []byte {
+	var (
+		le     = binary.LittleEndian
+		stride = (2*bitCount + 31) / 32 * 4
+		buf    = make([]byte, 40)
+	)
+	le.PutUint32(buf[0:], 40)
+	le.PutUint32(buf[4:], 2)
+	le.PutUint32(buf[8:], 4)
+	le.PutUint16(buf[12:], 1)
+	le.PutUint16(buf[14:], uint16(bitCount))
+	le.PutUint32(buf[32:], uint32(len(palette)))
+	for _, c := range palette {
+		buf = append(buf, c.B, c.G, c.R, 0)
+	}
+	// Rows are stored bottom up.
+	for _, y := range []int{1, 0} {
+		row := make([]byte, stride)
+		for x := 0; x < 2; x++ {
+			p := pixels[y*2+x]
+			switch bitCount {
+			case 1:
+				row[0] |= uint8(p) << (7 - x)
+			case 4:
+				row[0] |= uint8(p) << (4 * (1 - x))
+			case 8:
+				row[x] = uint8(p)
+			case 24:
+				row[x*3], row[x*3+1], row[x*3+2] = uint8(p), uint8(p>>8), uint8(p>>16)
+			case 32:
+				le.PutUint32(row[x*4:], p)
+			}
+		}
+		buf = append(buf, row...)
+	}
+	// The mask has a bottom row with its second pixel set, then a top row
+	// that is clear.
+	return append(buf, 0x40, 0, 0, 0, 0, 0, 0, 0)
+}
+
+// icoFile wraps png or bmp entries in an ICO directory, listing their
+// dimensions.
+func icoFile(entries ...[]byte) []byte {
+	var (
+		le     = binary.LittleEndian
+		buf    = []byte{0, 0, 1, 0, uint8(len(entries)), 0}
+		offset = 6 + 16*len(entries)
+	)
+	for _, e := range entries {
+		dir := make([]byte, 16)
+		if bytes.HasPrefix(e, pngSignature) {
+			dir[0], dir[1] = uint8(binary.BigEndian.Uint32(e[16:])), uint8(binary.BigEndian.Uint32(e[20:]))
+		} else {
+			dir[0], dir[1] = uint8(le.Uint32(e[4:])), uint8(le.Uint32(e[8:])/2)
+		}
+		le.PutUint32(dir[8:], uint32(len(e)))
+		le.PutUint32(dir[12:], uint32(offset))
+		buf = append(buf, dir...)
+		offset += len(e)
+	}
+	for _, e := range entries {
+		buf = append(buf, e...)
+	}
+	return buf
+}
+
+func sameImage(a, b image.Image) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	var (
+		ab = a.Bounds()
+		bb = b.Bounds()
+	)
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ac := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y))
+			bc := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y))
+			if ac != bc {
+				return false
+			}
+		}
+	}
+	return true
+}
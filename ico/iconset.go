@@ -0,0 +1,76 @@
+package ico
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/jackmordaunt/icns/v3"
+)
+
+// IconSet creates an icns IconSet from an ICO file, rendering every icon
+// from its largest image through pipeline. When pipeline only resizes, png
+// images of an icon's exact size are kept unchanged instead, as the artist
+// drew them.
+func IconSet(r io.Reader, pipeline *icns.Encoder) (*icns.IconSet, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	largest, err := decodeEntry(entries[0].data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding largest image: %w", err)
+	}
+	if pipeline == nil {
+		pipeline = icns.NewEncoder(nil)
+	}
+	set, err := pipeline.IconSet(largest)
+	if err != nil || !resizesOnly(pipeline) {
+		// Sets over the size budget are returned with the error.
+		return set, err
+	}
+	for ii, icon := range set.Icons {
+		if icon == nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.width != int(icon.Type.Size) || e.height != e.width || !bytes.HasPrefix(e.data, pngSignature) {
+				continue
+			}
+			if pipeline.BitDepth == icns.BitDepth8 && pngBitDepth(e.data) > 8 {
+				break
+			}
+			if kept, err := icns.NewPNGIcon(icon.Type, e.data); err == nil {
+				set.Icons[ii] = kept
+			}
+			break
+		}
+	}
+	return set, nil
+}
+
+// resizesOnly reports whether enc leaves the artwork of an image that is
+// already the right size unchanged. Size budgets and observers need every
+// icon encoded by enc, so that they see the icns as written. Fitting or
+// cropping a non-square largest image changes what every icon shows, so
+// only the default stretch keeps images.
+func resizesOnly(enc *icns.Encoder) bool {
+	return enc.Fit == icns.FitStretch &&
+		enc.Crop.Empty() &&
+		len(enc.Filters) == 0 &&
+		enc.Style == nil &&
+		enc.Trim == nil &&
+		enc.ColorSpace == icns.ColorSpaceNone &&
+		!enc.Optimize &&
+		enc.SizeBudget == 0 &&
+		enc.Observer == nil
+}
+
+// pngBitDepth returns the bits per sample of png data, from its header.
+func pngBitDepth(data []byte) int {
+	// The signature, then the IHDR length, type, width and height.
+	if len(data) < 25 {
+		return 0
+	}
+	return int(data[24])
+}
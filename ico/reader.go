@@ -0,0 +1,219 @@
+package ico
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// entry is an image listed in the directory of an ICO file.
+type entry struct {
+	width, height int
+	data          []byte
+}
+
+// Decode returns the largest image in the ICO file.
+func Decode(r io.Reader) (image.Image, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodeEntry(entries[0].data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding largest image: %w", err)
+	}
+	return img, nil
+}
+
+// DecodeAll returns every image in the ICO file, largest first.
+func DecodeAll(r io.Reader) ([]image.Image, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]image.Image, len(entries))
+	for ii, e := range entries {
+		if images[ii], err = decodeEntry(e.data); err != nil {
+			return nil, fmt.Errorf("decoding %dx%d image: %w", e.width, e.height, err)
+		}
+	}
+	return images, nil
+}
+
+// DecodeConfig returns the dimensions of the largest image in the ICO file,
+// as listed in its directory.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      entries[0].width,
+		Height:     entries[0].height,
+	}, nil
+}
+
+// readEntries reads the directory of an ICO file, largest entry first.
+func readEntries(r io.Reader) ([]entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 6 || binary.LittleEndian.Uint16(data) != 0 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, errors.New("ico: invalid header")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 {
+		return nil, errors.New("ico: no images")
+	}
+	if len(data) < 6+16*count {
+		return nil, errors.New("ico: directory truncated")
+	}
+	entries := make([]entry, count)
+	for ii := range entries {
+		var (
+			dir    = data[6+16*ii:]
+			size   = int(binary.LittleEndian.Uint32(dir[8:]))
+			offset = int(binary.LittleEndian.Uint32(dir[12:]))
+		)
+		if size < 0 || offset < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("ico: image %d out of bounds", ii)
+		}
+		e := entry{width: int(dir[0]), height: int(dir[1]), data: data[offset : offset+size]}
+		// A width or height of 0 means 256.
+		if e.width == 0 {
+			e.width = 256
+		}
+		if e.height == 0 {
+			e.height = 256
+		}
+		entries[ii] = e
+	}
+	sort.SliceStable(entries, func(ii, jj int) bool {
+		return entries[ii].width*entries[ii].height > entries[jj].width*entries[jj].height
+	})
+	return entries, nil
+}
+
+func decodeEntry(data []byte) (image.Image, error) {
+	if bytes.HasPrefix(data, pngSignature) {
+		return png.Decode(bytes.NewReader(data))
+	}
+	return decodeBMP(data)
+}
+
+// decodeBMP decodes a bmp entry: a bottom up, uncompressed bitmap of 1, 4, 8,
+// 24 or 32 bits per pixel without a file header, followed by an AND mask.
+func decodeBMP(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errors.New("bmp header truncated")
+	}
+	var (
+		le          = binary.LittleEndian
+		headerSize  = int(le.Uint32(data))
+		w           = int(int32(le.Uint32(data[4:])))
+		h           = int(int32(le.Uint32(data[8:]))) / 2
+		bitCount    = int(le.Uint16(data[14:]))
+		compression = le.Uint32(data[16:])
+		colorsUsed  = int(le.Uint32(data[32:]))
+	)
+	if headerSize < 40 || headerSize > len(data) || w <= 0 || h <= 0 || w > 1<<12 || h > 1<<12 {
+		return nil, fmt.Errorf("invalid bmp header")
+	}
+	if compression != 0 {
+		return nil, fmt.Errorf("unsupported bmp compression %d", compression)
+	}
+	var (
+		offset  = headerSize
+		palette []color.NRGBA
+	)
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		if offset+colorsUsed*4 > len(data) {
+			return nil, errors.New("bmp palette truncated")
+		}
+		for ii := 0; ii < colorsUsed; ii++ {
+			p := data[offset+ii*4:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff})
+		}
+		offset += colorsUsed * 4
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported bmp depth %d", bitCount)
+	}
+	var (
+		stride     = (w*bitCount + 31) / 32 * 4
+		maskStride = (w + 31) / 32 * 4
+		mask       []byte
+		img        = image.NewNRGBA(image.Rect(0, 0, w, h))
+		alpha      bool
+	)
+	if offset+stride*h > len(data) {
+		return nil, errors.New("bmp pixels truncated")
+	}
+	// Some writers leave the mask out of 32 bit entries.
+	if end := offset + stride*h; end+maskStride*h <= len(data) {
+		mask = data[end : end+maskStride*h]
+	}
+	for y := 0; y < h; y++ {
+		row := data[offset+(h-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			var (
+				c   color.NRGBA
+				idx int
+			)
+			switch bitCount {
+			case 1:
+				idx = int(row[x/8]>>(7-x%8)) & 1
+			case 4:
+				idx = int(row[x/2]>>(4*(1-x%2))) & 0xf
+			case 8:
+				idx = int(row[x])
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				alpha = alpha || c.A != 0
+			}
+			if palette != nil {
+				if idx >= len(palette) {
+					return nil, fmt.Errorf("bmp colour index %d out of range", idx)
+				}
+				c = palette[idx]
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	if bitCount == 32 && alpha {
+		return img, nil
+	}
+	// Without an alpha channel, or when it is unused, the AND mask marks
+	// transparent pixels.
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[y*img.Stride+x*4:]
+			if mask != nil && mask[(h-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+			} else {
+				p[3] = 0xff
+			}
+		}
+	}
+	return img, nil
+}
+
+func init() {
+	image.RegisterFormat("ico", "\x00\x00\x01\x00", Decode, DecodeConfig)
+}
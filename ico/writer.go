@@ -0,0 +1,206 @@
+// Package ico encodes and decodes Windows ICO files.
+//
+// Entries are resized through an icns.Encoder, so the resampler, sharpening,
+// filters and style chosen for an icns file apply to its ICO counterpart.
+package ico
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/jackmordaunt/icns/v3"
+)
+
+// Sizes are the entry sizes (in px) written by default.
+var Sizes = []uint{256, 64, 48, 32, 24, 16}
+
+// Format decides how entries are stored.
+type Format int
+
+// Format constants.
+const (
+	// FormatAuto stores 256px entries as png and smaller ones as bmp, as
+	// Windows does, for the widest compatibility.
+	FormatAuto Format = iota
+	// FormatPNG stores every entry as png, for the smallest files.
+	FormatPNG
+	// FormatBMP stores every entry as a 32 bit bmp with an AND mask.
+	FormatBMP
+)
+
+// Encoder encodes ICO files from a source image.
+type Encoder struct {
+	Wr io.Writer
+	// Pipeline resizes the source to each entry size.
+	// Defaults to icns.NewEncoder(nil) if nil.
+	Pipeline *icns.Encoder
+	// Sizes of the entries (in px), up to 256. Defaults to Sizes if empty.
+	Sizes []uint
+	// Format of the entries.
+	Format Format
+}
+
+// NewEncoder initialises an encoder.
+func NewEncoder(wr io.Writer) *Encoder {
+	return &Encoder{Wr: wr}
+}
+
+// WithPipeline resizes entries with p, applying all of its options.
+func (enc *Encoder) WithPipeline(p *icns.Encoder) *Encoder {
+	enc.Pipeline = p
+	return enc
+}
+
+// WithSizes writes an entry for each size (in px).
+func (enc *Encoder) WithSizes(sizes ...uint) *Encoder {
+	enc.Sizes = sizes
+	return enc
+}
+
+// WithFormat stores entries in the given format.
+func (enc *Encoder) WithFormat(f Format) *Encoder {
+	enc.Format = f
+	return enc
+}
+
+// Encode writes img as an ICO file.
+func (enc *Encoder) Encode(img image.Image) error {
+	sizes, err := enc.sizes()
+	if err != nil {
+		return err
+	}
+	images, err := enc.pipeline().Images(img, sizes)
+	if err != nil {
+		return err
+	}
+	return enc.write(images)
+}
+
+// EncodeSource writes an ICO file from a source rendered at each size.
+func (enc *Encoder) EncodeSource(p icns.SourceProvider) error {
+	sizes, err := enc.sizes()
+	if err != nil {
+		return err
+	}
+	images, err := enc.pipeline().ImagesSource(p, sizes)
+	if err != nil {
+		return err
+	}
+	return enc.write(images)
+}
+
+// Encode writes img to wr as an ICO file with the default sizes.
+func Encode(wr io.Writer, img image.Image) error {
+	return NewEncoder(wr).Encode(img)
+}
+
+func (enc *Encoder) pipeline() *icns.Encoder {
+	if enc.Pipeline == nil {
+		return icns.NewEncoder(nil)
+	}
+	return enc.Pipeline
+}
+
+func (enc *Encoder) sizes() ([]uint, error) {
+	sizes := enc.Sizes
+	if len(sizes) == 0 {
+		sizes = Sizes
+	}
+	for _, size := range sizes {
+		if size == 0 || size > 256 {
+			return nil, fmt.Errorf("ico: entries are 1 to 256px, got %dpx", size)
+		}
+	}
+	return sizes, nil
+}
+
+// write writes images as entries of an ICO file.
+func (enc *Encoder) write(images []image.Image) error {
+	if enc.Wr == nil {
+		return errors.New("cannot write to nil writer")
+	}
+	var (
+		header = make([]byte, 6+16*len(images))
+		body   = bytes.NewBuffer(nil)
+	)
+	binary.LittleEndian.PutUint16(header[2:], 1)
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(images)))
+	for ii, img := range images {
+		var (
+			src   = toNRGBA(img)
+			entry = header[6+16*ii:]
+			data  []byte
+		)
+		if enc.Format == FormatPNG || (enc.Format == FormatAuto && src.Rect.Dx() >= 256) {
+			buf := bytes.NewBuffer(nil)
+			if err := png.Encode(buf, src); err != nil {
+				return fmt.Errorf("encoding %dpx entry: %w", src.Rect.Dx(), err)
+			}
+			data = buf.Bytes()
+		} else {
+			data = encodeBMP(src)
+		}
+		// A width or height of 256 is stored as 0.
+		entry[0], entry[1] = uint8(src.Rect.Dx()), uint8(src.Rect.Dy())
+		binary.LittleEndian.PutUint16(entry[4:], 1)
+		binary.LittleEndian.PutUint16(entry[6:], 32)
+		binary.LittleEndian.PutUint32(entry[8:], uint32(len(data)))
+		binary.LittleEndian.PutUint32(entry[12:], uint32(len(header)+body.Len()))
+		body.Write(data)
+	}
+	if _, err := enc.Wr.Write(header); err != nil {
+		return err
+	}
+	_, err := body.WriteTo(enc.Wr)
+	return err
+}
+
+// encodeBMP encodes src as a bottom up, 32 bit bmp without a file header,
+// followed by an AND mask marking the fully transparent pixels.
+func encodeBMP(src *image.NRGBA) []byte {
+	var (
+		w, h       = src.Rect.Dx(), src.Rect.Dy()
+		maskStride = (w + 31) / 32 * 4
+		buf        = make([]byte, 40+w*h*4+maskStride*h)
+		pix        = buf[40:]
+		mask       = buf[40+w*h*4:]
+	)
+	binary.LittleEndian.PutUint32(buf[0:], 40)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(w))
+	// The height covers both the colours and the mask.
+	binary.LittleEndian.PutUint32(buf[8:], uint32(h*2))
+	binary.LittleEndian.PutUint16(buf[12:], 1)
+	binary.LittleEndian.PutUint16(buf[14:], 32)
+	binary.LittleEndian.PutUint32(buf[20:], uint32(len(buf)-40))
+	for y := 0; y < h; y++ {
+		row := h - 1 - y
+		for x := 0; x < w; x++ {
+			var (
+				c = src.Pix[y*src.Stride+x*4:]
+				p = pix[(row*w+x)*4:]
+			)
+			p[0], p[1], p[2], p[3] = c[2], c[1], c[0], c[3]
+			if c[3] == 0 {
+				mask[row*maskStride+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return buf
+}
+
+// toNRGBA returns img as an *image.NRGBA with its origin at (0, 0).
+func toNRGBA(img image.Image) *image.NRGBA {
+	if src, ok := img.(*image.NRGBA); ok && src.Rect.Min == (image.Point{}) {
+		return src
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA(b.Sub(b.Min))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,181 @@
+package icns
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// iconsetNames are the file names of each icon type in an iconset directory,
+// as used by iconutil, in the order the Encoder creates them.
+var iconsetNames = []struct {
+	ID   string
+	Name string
+}{
+	{"ic10", "icon_512x512@2x.png"},
+	{"ic14", "icon_256x256@2x.png"},
+	{"ic09", "icon_512x512.png"},
+	{"ic13", "icon_128x128@2x.png"},
+	{"ic08", "icon_256x256.png"},
+	{"ic07", "icon_128x128.png"},
+	{"ic12", "icon_32x32@2x.png"},
+	{"ic11", "icon_16x16@2x.png"},
+	{"icp5", "icon_32x32.png"},
+	{"icp4", "icon_16x16.png"},
+}
+
+// ReadIconset builds an IconSet from an iconset directory in fsys, such as
+// AppIcon.iconset, as converted by iconutil. Every png must have a name
+// iconutil knows and the dimensions its name implies. Other files, such as
+// .DS_Store, are ignored.
+func ReadIconset(fsys fs.FS, dir string) (*IconSet, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]bool{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(path.Ext(e.Name()), ".png") {
+			continue
+		}
+		if !isIconsetName(e.Name()) {
+			return nil, fmt.Errorf("%s: not an iconset icon name", e.Name())
+		}
+		files[e.Name()] = true
+	}
+	set := &IconSet{}
+	for _, n := range iconsetNames {
+		if !files[n.Name] {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, n.Name))
+		if err != nil {
+			return nil, err
+		}
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name, err)
+		}
+		var (
+			t    = osTypeFromID(n.ID)
+			size = img.Bounds().Size()
+		)
+		if size.X != int(t.Size) || size.Y != int(t.Size) {
+			return nil, fmt.Errorf("%s: want %dx%d, got %dx%d", n.Name, t.Size, t.Size, size.X, size.Y)
+		}
+		icon := &Icon{Type: t, Image: img}
+		if format == "png" {
+			icon.data = data
+		}
+		set.Icons = append(set.Icons, icon)
+	}
+	if len(set.Icons) == 0 {
+		return nil, fmt.Errorf("no icons in %s", dir)
+	}
+	return set, nil
+}
+
+// IconsetFile is a png in an iconset directory.
+type IconsetFile struct {
+	Name string
+	Data []byte
+}
+
+// IconsetFiles returns the files of an iconset directory holding every icon
+// in set, under the names iconutil expects. iconutil also expects the 16px
+// and 32px icons, which an Encoder does not create: they are resized from the
+// smallest larger icon when set lacks them.
+func IconsetFiles(set *IconSet) ([]IconsetFile, error) {
+	icons := map[string]*Icon{}
+	for _, icon := range set.Icons {
+		if icon == nil {
+			continue
+		}
+		if _, ok := iconsetName(icon.Type.ID); !ok {
+			return nil, fmt.Errorf("%q icon has no iconset name", icon.Type.ID)
+		}
+		icons[icon.Type.ID] = icon
+	}
+	for _, t := range legacyTypes {
+		if icons[t.ID] != nil {
+			continue
+		}
+		if icon := resizedIcon(set, t); icon != nil {
+			icons[t.ID] = icon
+		}
+	}
+	var files []IconsetFile
+	for _, n := range iconsetNames {
+		icon := icons[n.ID]
+		if icon == nil {
+			continue
+		}
+		if err := icon.encodeImage(); err != nil {
+			return nil, fmt.Errorf("encoding %s: %w", n.Name, err)
+		}
+		files = append(files, IconsetFile{Name: n.Name, Data: icon.data})
+	}
+	return files, nil
+}
+
+// WriteIconset writes the files of IconsetFiles into the directory dir,
+// creating it if needed.
+func WriteIconset(dir string, set *IconSet) error {
+	files, err := IconsetFiles(set)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.Name), f.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resizedIcon returns an icon of type t from the smallest icon in set that
+// is at least as large, reusing its data if it is the same size.
+func resizedIcon(set *IconSet, t OsType) *Icon {
+	var src *Icon
+	for _, icon := range set.Icons {
+		if icon == nil || icon.Type.Size < t.Size {
+			continue
+		}
+		if src == nil || icon.Type.Size < src.Type.Size {
+			src = icon
+		}
+	}
+	if src == nil {
+		return nil
+	}
+	if src.Type.Size == t.Size {
+		return &Icon{Type: t, Image: src.Image, data: src.data}
+	}
+	return &Icon{Type: t, Image: Interpolate(MitchellNetravali).Resize(src.Image, t.Size, t.Size)}
+}
+
+func iconsetName(id string) (string, bool) {
+	for _, n := range iconsetNames {
+		if n.ID == id {
+			return n.Name, true
+		}
+	}
+	return "", false
+}
+
+func isIconsetName(name string) bool {
+	for _, n := range iconsetNames {
+		if n.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package icns
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIconsetRoundTrip(t *testing.T) {
+	t.Parallel()
+	set, err := NewEncoder(nil).IconSet(opaqueImage(1024))
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	files, err := IconsetFiles(set)
+	if err != nil {
+		t.Fatalf("creating iconset: %v", err)
+	}
+	var (
+		names []string
+		fsys  = fstest.MapFS{}
+	)
+	for _, f := range files {
+		names = append(names, f.Name)
+		fsys["AppIcon.iconset/"+f.Name] = &fstest.MapFile{Data: f.Data}
+	}
+	// The 16px and 32px icons iconutil expects are added.
+	want := []string{
+		"icon_128x128.png", "icon_128x128@2x.png",
+		"icon_16x16.png", "icon_16x16@2x.png",
+		"icon_256x256.png", "icon_256x256@2x.png",
+		"icon_32x32.png", "icon_32x32@2x.png",
+		"icon_512x512.png", "icon_512x512@2x.png",
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("want files %v, got %v", want, names)
+	}
+	got, err := ReadIconset(fsys, "AppIcon.iconset")
+	if err != nil {
+		t.Fatalf("reading iconset: %v", err)
+	}
+	var ids []string
+	for _, icon := range got.Icons[len(set.Icons):] {
+		if icon.Image.Bounds().Dx() != int(icon.Type.Size) {
+			t.Errorf("%s: want %dpx, got %dpx", icon.Type.ID, icon.Type.Size, icon.Image.Bounds().Dx())
+		}
+		ids = append(ids, icon.Type.ID)
+	}
+	if got, want := strings.Join(ids, " "), "icp5 icp4"; got != want {
+		t.Errorf("want added types %q, got %q", want, got)
+	}
+	var before, after bytes.Buffer
+	if _, err := set.WriteTo(&before); err != nil {
+		t.Fatalf("writing icns: %v", err)
+	}
+	if _, err := (&IconSet{Icons: got.Icons[:len(set.Icons)]}).WriteTo(&after); err != nil {
+		t.Fatalf("writing icns: %v", err)
+	}
+	if !bytes.Equal(before.Bytes(), after.Bytes()) {
+		t.Errorf("icns differs after a round trip through an iconset")
+	}
+}
+
+func TestWriteIconset(t *testing.T) {
+	t.Parallel()
+	set, err := NewEncoder(nil).IconSet(opaqueImage(256))
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "AppIcon.iconset")
+	if err := WriteIconset(dir, set); err != nil {
+		t.Fatalf("writing iconset: %v", err)
+	}
+	got, err := ReadIconset(os.DirFS(filepath.Dir(dir)), filepath.Base(dir))
+	if err != nil {
+		t.Fatalf("reading iconset: %v", err)
+	}
+	read := map[string][]byte{}
+	for _, icon := range got.Icons {
+		read[icon.Type.ID] = icon.data
+	}
+	for _, icon := range set.Icons {
+		if icon == nil {
+			continue
+		}
+		data, ok := read[icon.Type.ID]
+		if !ok {
+			t.Errorf("%s: missing after a round trip", icon.Type.ID)
+			continue
+		}
+		if !bytes.Equal(data, icon.data) {
+			t.Errorf("%s: differs after a round trip", icon.Type.ID)
+		}
+	}
+}
+
+func TestReadIconset(t *testing.T) {
+	t.Parallel()
+	icon := func(side int) *fstest.MapFile {
+		buf := bytes.NewBuffer(nil)
+		if err := png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, side, side))); err != nil {
+			t.Fatalf("encoding png: %v", err)
+		}
+		return &fstest.MapFile{Data: buf.Bytes()}
+	}
+	tests := []struct {
+		desc  string
+		files fstest.MapFS
+		// want is the types read, or the start of the error.
+		want string
+	}{
+		{
+			"legacy and retina",
+			fstest.MapFS{
+				"a.iconset/icon_16x16.png":    icon(16),
+				"a.iconset/icon_16x16@2x.png": icon(32),
+				"a.iconset/icon_32x32.png":    icon(32),
+				"a.iconset/.DS_Store":         &fstest.MapFile{},
+			},
+			"ic11 icp5 icp4",
+		},
+		{
+			"wrong dimensions",
+			fstest.MapFS{"a.iconset/icon_128x128@2x.png": icon(128)},
+			"icon_128x128@2x.png: want 256x256, got 128x128",
+		},
+		{
+			"unknown name",
+			fstest.MapFS{"a.iconset/icon_64x64.png": icon(64)},
+			"icon_64x64.png: not an iconset icon name",
+		},
+		{
+			"empty",
+			fstest.MapFS{"a.iconset/readme.txt": &fstest.MapFile{}},
+			"no icons in a.iconset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			set, err := ReadIconset(tt.files, "a.iconset")
+			if err != nil {
+				if !strings.HasPrefix(err.Error(), tt.want) {
+					st.Fatalf("want error %q, got %v", tt.want, err)
+				}
+				return
+			}
+			var ids []string
+			for _, icon := range set.Icons {
+				ids = append(ids, icon.Type.ID)
+			}
+			if got := strings.Join(ids, " "); got != tt.want {
+				st.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeIconSet(t *testing.T) {
+	t.Parallel()
+	buf := bytes.NewBuffer(nil)
+	if err := Encode(buf, opaqueImage(256)); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	original := append([]byte(nil), buf.Bytes()...)
+	set, err := NewDecoder(buf).DecodeIconSet()
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	var ids []string
+	for _, icon := range set.Icons {
+		if icon.Image.Bounds().Dx() != int(icon.Type.Size) {
+			t.Errorf("%s: want %dpx, got %dpx", icon.Type.ID, icon.Type.Size, icon.Image.Bounds().Dx())
+		}
+		ids = append(ids, icon.Type.ID)
+	}
+	if got, want := strings.Join(ids, " "), "ic13 ic08 ic07 ic12 ic11"; got != want {
+		t.Errorf("want types %q, got %q", want, got)
+	}
+	reencoded := bytes.NewBuffer(nil)
+	if _, err := set.WriteTo(reencoded); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if !bytes.Equal(reencoded.Bytes(), original) {
+		t.Errorf("want decoded icons written back unchanged")
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	t.Parallel()
+	buf := bytes.NewBuffer(nil)
+	if err := Encode(buf, opaqueImage(64)); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	// Append an icp4 icon of raw rgb data, as old icns files hold, and an
+	// icns holding only that.
+	var (
+		raw    = append([]byte("icp4\x00\x00\x03\x08"), bytes.Repeat([]byte{0x80}, 16*16*3)...)
+		data   = append(append([]byte(nil), buf.Bytes()...), raw...)
+		legacy = append([]byte("icns\x00\x00\x00\x00"), raw...)
+	)
+	for _, d := range [][]byte{data, legacy} {
+		size := uint32(len(d))
+		d[4], d[5], d[6], d[7] = byte(size>>24), byte(size>>16), byte(size>>8), byte(size)
+	}
+	set, err := NewDecoder(bytes.NewReader(data)).DecodeIconSet()
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	var ids []string
+	for _, icon := range set.Icons {
+		ids = append(ids, icon.Type.ID)
+	}
+	if got, want := strings.Join(ids, " "), "ic12 ic11"; got != want {
+		t.Errorf("want types %q, got %q", want, got)
+	}
+	if img, err := Decode(bytes.NewReader(data)); err != nil || img.Bounds().Dx() != 64 {
+		t.Errorf("want the 64px icon decoded, got %v", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader(legacy)).DecodeIconSet(); err == nil {
+		t.Errorf("want error for an icns without decodable icons, got nil")
+	}
+}
@@ -0,0 +1,79 @@
+// Package paint holds the drawing helpers shared by the badge filter and the
+// placeholder icons.
+package paint
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+// RoundedRect returns the antialiased coverage of a w by h rectangle with
+// corners rounded to radius (in px).
+func RoundedRect(w, h int, radius float64) *image.Alpha16 {
+	var (
+		mask = image.NewAlpha16(image.Rect(0, 0, w, h))
+		fw   = float64(w)
+		fh   = float64(h)
+	)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Distance from the pixel centre to the rectangle inset by the
+			// radius, less the radius.
+			var (
+				px = float64(x) + 0.5
+				py = float64(y) + 0.5
+				dx = math.Max(math.Max(radius-px, px-(fw-radius)), 0)
+				dy = math.Max(math.Max(radius-py, py-(fh-radius)), 0)
+				d  = math.Hypot(dx, dy) - radius
+			)
+			coverage := math.Max(0, math.Min(1, 0.5-d))
+			mask.SetAlpha16(x, y, color.Alpha16{A: uint16(coverage * 0xffff)})
+		}
+	}
+	return mask
+}
+
+var (
+	bold     *sfnt.Font
+	boldErr  error
+	boldOnce sync.Once
+)
+
+// Bold returns Go Bold with the given cap height (in px).
+func Bold(capHeight float64) (font.Face, error) {
+	boldOnce.Do(func() {
+		bold, boldErr = opentype.Parse(gobold.TTF)
+	})
+	if boldErr != nil {
+		return nil, boldErr
+	}
+	// Go Bold's cap height is about 0.72 of its em square.
+	return opentype.NewFace(bold, &opentype.FaceOptions{
+		Size:    math.Max(capHeight/0.72, 1),
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+}
+
+// SRGBToLinear decodes an sRGB encoded value in [0, 1] to linear light.
+func SRGBToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// LinearToSRGB encodes a linear light value in [0, 1] as sRGB.
+func LinearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
@@ -1,10 +1,19 @@
 package icns
 
 import (
+	"image"
+
 	"github.com/nfnt/resize"
+	"golang.org/x/image/draw"
 )
 
+// Resampler resizes an image to the given dimensions (in px).
+type Resampler interface {
+	Resize(img image.Image, width, height uint) image.Image
+}
+
 // InterpolationFunction is the algorithm used to resize the image.
+// Wrap it with Interpolate to use it as a Resampler.
 type InterpolationFunction = resize.InterpolationFunction
 
 // InterpolationFunction constants.
@@ -22,3 +31,46 @@ const (
 	// Lanczos interpolation (a=3)
 	Lanczos3
 )
+
+// Interpolate adapts an interpolation function to a Resampler, resizing with
+// github.com/nfnt/resize.
+func Interpolate(f InterpolationFunction) Resampler {
+	return interpolation{f}
+}
+
+type interpolation struct {
+	f InterpolationFunction
+}
+
+func (i interpolation) Resize(img image.Image, width, height uint) image.Image {
+	return resize.Resize(width, height, img, i.f)
+}
+
+// DrawResampler adapts an interpolator from golang.org/x/image/draw.
+type DrawResampler struct {
+	draw.Interpolator
+}
+
+// DrawResampler values.
+var (
+	// Catmull-Rom interpolation
+	CatmullRom = DrawResampler{draw.CatmullRom}
+	// Approximate bilinear interpolation, faster but lower quality than
+	// Bilinear.
+	ApproxBiLinear = DrawResampler{draw.ApproxBiLinear}
+)
+
+// Resize scales img using the interpolator.
+// The result is an *image.RGBA64 for 16 bit sources, otherwise an
+// *image.RGBA.
+func (r DrawResampler) Resize(img image.Image, width, height uint) image.Image {
+	var (
+		bounds            = image.Rect(0, 0, int(width), int(height))
+		dst    draw.Image = image.NewRGBA(bounds)
+	)
+	if is16Bit(img) {
+		dst = image.NewRGBA64(bounds)
+	}
+	r.Scale(dst, bounds, img, img.Bounds(), draw.Src, nil)
+	return dst
+}
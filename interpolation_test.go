@@ -0,0 +1,127 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResamplers(t *testing.T) {
+	t.Parallel()
+	var (
+		red   = color.NRGBA{R: 0xff, A: 0xff}
+		nrgba = image.NewNRGBA(image.Rect(3, 5, 103, 105))
+		rgba  = image.NewRGBA(image.Rect(0, 0, 100, 100))
+		gray  = image.NewGray16(image.Rect(0, 0, 100, 100))
+	)
+	draw.Draw(nrgba, nrgba.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(gray, gray.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	tests := []struct {
+		desc string
+		r    Resampler
+		img  image.Image
+		want color.Color
+	}{
+		{"interpolation function", Interpolate(Lanczos3), nrgba, red},
+		{"catmull-rom", CatmullRom, nrgba, red},
+		{"approx bilinear", ApproxBiLinear, rgba, red},
+		{"lanczos nrgba", Lanczos{}, nrgba, red},
+		{"lanczos rgba", Lanczos{A: 2}, rgba, red},
+		{"lanczos generic", Lanczos{}, gray, color.White},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			for _, size := range []uint{16, 37, 256} {
+				got := tt.r.Resize(tt.img, size, size)
+				if want := image.Rect(0, 0, int(size), int(size)); got.Bounds() != want {
+					st.Fatalf("bounds: want=%v, got=%v", want, got.Bounds())
+				}
+				// A uniform image must stay uniform, including at the edges.
+				for _, p := range []image.Point{{0, 0}, {int(size) / 2, int(size) / 3}, {int(size) - 1, int(size) - 1}} {
+					wr, wg, wb, wa := tt.want.RGBA()
+					r, g, b, a := got.At(p.X, p.Y).RGBA()
+					if diff(wr, r) > 0x101 || diff(wg, g) > 0x101 || diff(wb, b) > 0x101 || diff(wa, a) > 0x101 {
+						st.Errorf("size %d at %v: want=%v, got=%v", size, p, tt.want, got.At(p.X, p.Y))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLanczosKeepsFastPathTypes(t *testing.T) {
+	t.Parallel()
+	if _, ok := (Lanczos{}).Resize(image.NewNRGBA(image.Rect(0, 0, 8, 8)), 4, 4).(*image.NRGBA); !ok {
+		t.Errorf("want *image.NRGBA")
+	}
+	if _, ok := (Lanczos{}).Resize(image.NewRGBA(image.Rect(0, 0, 8, 8)), 4, 4).(*image.RGBA); !ok {
+		t.Errorf("want *image.RGBA")
+	}
+	if _, ok := (Lanczos{}).Resize(image.NewGray(image.Rect(0, 0, 8, 8)), 4, 4).(*image.RGBA64); !ok {
+		t.Errorf("want *image.RGBA64")
+	}
+}
+
+type countingResampler struct {
+	calls int32
+}
+
+func (c *countingResampler) Resize(img image.Image, width, height uint) image.Image {
+	atomic.AddInt32(&c.calls, 1)
+	return image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+}
+
+func TestLanczosPremultipliesNRGBA(t *testing.T) {
+	t.Parallel()
+	// Opaque red beside transparent green: no green may bleed into the edge.
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.NRGBA{G: 0xff}
+			if x < 32 {
+				c = color.NRGBA{R: 0xff, A: 0xff}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+	got := (Lanczos{}).Resize(src, 24, 24).(*image.NRGBA)
+	for x := 0; x < 24; x++ {
+		if c := got.NRGBAAt(x, 12); c.A > 0 && c.G > 1 {
+			t.Errorf("x %d: want no green, got %v", x, c)
+		}
+	}
+}
+
+func TestCustomResampler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc    string
+		iconSet func(img image.Image, r Resampler) (*IconSet, error)
+	}{
+		{"Encoder", func(img image.Image, r Resampler) (*IconSet, error) {
+			return NewEncoder(nil).WithResampler(r).IconSet(img)
+		}},
+		{"NewIconSetResampler", NewIconSetResampler},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			r := &countingResampler{}
+			if _, err := tt.iconSet(rect(0, 0, 64, 64), r); err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			if r.calls == 0 {
+				st.Errorf("custom resampler was not used")
+			}
+		})
+	}
+}
+
+func diff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
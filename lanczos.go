@@ -0,0 +1,232 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Lanczos is a native Lanczos resampler with fast paths for *image.NRGBA and
+// *image.RGBA. Other image types are resampled at 16 bits per channel.
+type Lanczos struct {
+	// A is the number of lobes, defaulting to 3.
+	A int
+}
+
+// Resize scales img using a separable Lanczos filter.
+// The result has the same type as img for *image.NRGBA and *image.RGBA,
+// otherwise it is an *image.RGBA64.
+func (l Lanczos) Resize(img image.Image, width, height uint) image.Image {
+	var (
+		b   = img.Bounds()
+		dst = image.Rect(0, 0, int(width), int(height))
+	)
+	if dst.Empty() || b.Empty() {
+		return image.NewRGBA(dst)
+	}
+	a := float64(l.A)
+	if a <= 0 {
+		a = 3
+	}
+	var (
+		pix = planes(img)
+		xw  = lanczosWeights(dst.Dx(), b.Dx(), a)
+		yw  = lanczosWeights(dst.Dy(), b.Dy(), a)
+	)
+	pix = convolve(pix, b.Dx(), b.Dy(), xw, true)
+	pix = convolve(pix, dst.Dx(), b.Dy(), yw, false)
+	switch img.(type) {
+	case *image.NRGBA:
+		out := image.NewNRGBA(dst)
+		for ii := 0; ii < len(pix); ii += 4 {
+			a := clamp(pix[ii+3], 1)
+			if a == 0 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				out.Pix[ii+c] = uint8(clamp(pix[ii+c], a)/a*0xff + 0.5)
+			}
+			out.Pix[ii+3] = uint8(a*0xff + 0.5)
+		}
+		return out
+	case *image.RGBA:
+		out := image.NewRGBA(dst)
+		for ii := 0; ii < len(pix); ii += 4 {
+			a := clamp(pix[ii+3], 1)
+			for c := 0; c < 4; c++ {
+				out.Pix[ii+c] = uint8(clamp(pix[ii+c], a)*0xff + 0.5)
+			}
+		}
+		return out
+	}
+	out := image.NewRGBA64(dst)
+	for ii := 0; ii < len(pix); ii += 4 {
+		a := clamp(pix[ii+3], 1)
+		for c := 0; c < 4; c++ {
+			v := uint16(clamp(pix[ii+c], a)*0xffff + 0.5)
+			out.Pix[(ii+c)*2] = uint8(v >> 8)
+			out.Pix[(ii+c)*2+1] = uint8(v)
+		}
+	}
+	return out
+}
+
+// planes flattens img into rows of premultiplied RGBA samples in the range
+// [0, 1].
+func planes(img image.Image) []float32 {
+	var (
+		b   = img.Bounds()
+		w   = b.Dx()
+		pix = make([]float32, w*b.Dy()*4)
+	)
+	switch src := img.(type) {
+	case *image.NRGBA:
+		eightBit(pix, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, w, b.Dy())
+		// Filtering non-premultiplied samples bleeds the colour of
+		// transparent pixels into their neighbours.
+		for ii := 0; ii < len(pix); ii += 4 {
+			a := pix[ii+3]
+			pix[ii+0] *= a
+			pix[ii+1] *= a
+			pix[ii+2] *= a
+		}
+	case *image.RGBA:
+		eightBit(pix, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, w, b.Dy())
+	default:
+		at := func(x, y int) color.RGBA64 {
+			return color.RGBA64Model.Convert(img.At(x, y)).(color.RGBA64)
+		}
+		if src, ok := img.(image.RGBA64Image); ok {
+			at = src.RGBA64At
+		}
+		ii := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := at(x, y)
+				pix[ii+0] = float32(c.R) / 0xffff
+				pix[ii+1] = float32(c.G) / 0xffff
+				pix[ii+2] = float32(c.B) / 0xffff
+				pix[ii+3] = float32(c.A) / 0xffff
+				ii += 4
+			}
+		}
+	}
+	return pix
+}
+
+func eightBit(dst []float32, src []uint8, stride, w, h int) {
+	for y := 0; y < h; y++ {
+		row := src[y*stride : y*stride+w*4]
+		for x, v := range row {
+			dst[y*w*4+x] = float32(v) / 0xff
+		}
+	}
+}
+
+// weights holds, per output sample, the first contributing input sample and
+// the normalised filter coefficients.
+type weights struct {
+	start  []int
+	coeffs [][]float32
+}
+
+func lanczosWeights(dst, src int, a float64) weights {
+	var (
+		scale   = float64(src) / float64(dst)
+		stretch = math.Max(scale, 1)
+		support = a * stretch
+		w       = weights{start: make([]int, dst), coeffs: make([][]float32, dst)}
+	)
+	for ii := 0; ii < dst; ii++ {
+		centre := (float64(ii)+0.5)*scale - 0.5
+		left := int(math.Ceil(centre - support))
+		right := int(math.Floor(centre + support))
+		if left < 0 {
+			left = 0
+		}
+		if right > src-1 {
+			right = src - 1
+		}
+		var (
+			coeffs = make([]float32, right-left+1)
+			sum    float64
+		)
+		for jj := range coeffs {
+			v := lanczos((float64(left+jj)-centre)/stretch, a)
+			coeffs[jj] = float32(v)
+			sum += v
+		}
+		if sum != 0 {
+			for jj := range coeffs {
+				coeffs[jj] /= float32(sum)
+			}
+		}
+		w.start[ii] = left
+		w.coeffs[ii] = coeffs
+	}
+	return w
+}
+
+func lanczos(x, a float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	if x <= -a || x >= a {
+		return 0
+	}
+	px := math.Pi * x
+	return a * math.Sin(px) * math.Sin(px/a) / (px * px)
+}
+
+// convolve filters pix (w by h RGBA samples) along one axis.
+// Horizontal passes produce len(ws.start) by h samples, vertical passes
+// produce w by len(ws.start) samples.
+func convolve(pix []float32, w, h int, ws weights, horizontal bool) []float32 {
+	n := len(ws.start)
+	if horizontal {
+		out := make([]float32, n*h*4)
+		for y := 0; y < h; y++ {
+			row := pix[y*w*4 : (y+1)*w*4]
+			for x := 0; x < n; x++ {
+				var r, g, b, a float32
+				for jj, c := range ws.coeffs[x] {
+					s := row[(ws.start[x]+jj)*4:]
+					r += s[0] * c
+					g += s[1] * c
+					b += s[2] * c
+					a += s[3] * c
+				}
+				o := (y*n + x) * 4
+				out[o], out[o+1], out[o+2], out[o+3] = r, g, b, a
+			}
+		}
+		return out
+	}
+	out := make([]float32, w*n*4)
+	for y := 0; y < n; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, b, a float32
+			for jj, c := range ws.coeffs[y] {
+				s := pix[((ws.start[y]+jj)*w+x)*4:]
+				r += s[0] * c
+				g += s[1] * c
+				b += s[2] * c
+				a += s[3] * c
+			}
+			o := (y*w + x) * 4
+			out[o], out[o+1], out[o+2], out[o+3] = r, g, b, a
+		}
+	}
+	return out
+}
+
+// clamp restricts v to the range [0, max].
+func clamp(v, max float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/jackmordaunt/icns/v3/internal/paint"
+)
+
+// Linear wraps r so that it filters in linear light with premultiplied alpha.
+// Filtering sRGB encoded, non-premultiplied pixels darkens anti-aliased edges
+// next to transparent pixels, producing fringes and halos.
+//
+// The source is converted to a linear light *image.RGBA64 before being handed
+// to r, and the result is converted back to an sRGB encoded *image.NRGBA, or
+// *image.NRGBA64 for 16 bit sources.
+func Linear(r Resampler) Resampler {
+	return linear{r}
+}
+
+type linear struct {
+	Resampler
+}
+
+func (l linear) Resize(img image.Image, width, height uint) image.Image {
+	return fromLinear(l.Resampler.Resize(toLinear(img), width, height), is16Bit(img))
+}
+
+var (
+	lutOnce sync.Once
+	// decodeLUT maps 16 bit sRGB encoded values to linear light.
+	decodeLUT []uint16
+	// encodeLUT maps 16 bit linear light values to sRGB encoding.
+	encodeLUT []uint16
+)
+
+func luts() ([]uint16, []uint16) {
+	lutOnce.Do(func() {
+		decodeLUT = make([]uint16, 1<<16)
+		encodeLUT = make([]uint16, 1<<16)
+		for ii := range decodeLUT {
+			v := float64(ii) / 0xffff
+			decodeLUT[ii] = uint16(paint.SRGBToLinear(v)*0xffff + 0.5)
+			encodeLUT[ii] = uint16(paint.LinearToSRGB(v)*0xffff + 0.5)
+		}
+	})
+	return decodeLUT, encodeLUT
+}
+
+// toLinear converts img to linear light with premultiplied alpha.
+func toLinear(img image.Image) *image.RGBA64 {
+	var (
+		decode, _ = luts()
+		b         = img.Bounds()
+		dst       = image.NewRGBA64(b.Sub(b.Min))
+		at        = func(x, y int) color.NRGBA64 {
+			return color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+		}
+	)
+	if src, ok := img.(*image.NRGBA); ok {
+		at = func(x, y int) color.NRGBA64 {
+			c := src.NRGBAAt(x, y)
+			return color.NRGBA64{
+				R: uint16(c.R) * 0x101,
+				G: uint16(c.G) * 0x101,
+				B: uint16(c.B) * 0x101,
+				A: uint16(c.A) * 0x101,
+			}
+		}
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := at(x, y)
+			a := uint32(c.A)
+			dst.SetRGBA64(x-b.Min.X, y-b.Min.Y, color.RGBA64{
+				R: uint16(uint32(decode[c.R]) * a / 0xffff),
+				G: uint16(uint32(decode[c.G]) * a / 0xffff),
+				B: uint16(uint32(decode[c.B]) * a / 0xffff),
+				A: c.A,
+			})
+		}
+	}
+	return dst
+}
+
+// fromLinear converts a linear light, premultiplied img to sRGB encoding,
+// with 16 bits per channel if sixteen is set.
+func fromLinear(img image.Image, sixteen bool) image.Image {
+	var (
+		_, encode = luts()
+		bounds    = img.Bounds()
+		dst       = image.NewNRGBA64(bounds.Sub(bounds.Min))
+	)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA64(x-bounds.Min.X, y-bounds.Min.Y, color.NRGBA64{
+				R: encode[unpremultiply(r, a)],
+				G: encode[unpremultiply(g, a)],
+				B: encode[unpremultiply(b, a)],
+				A: uint16(a),
+			})
+		}
+	}
+	if sixteen {
+		return dst
+	}
+	return toNRGBA(dst)
+}
+
+func unpremultiply(c, a uint32) uint32 {
+	c = c * 0xffff / a
+	if c > 0xffff {
+		c = 0xffff
+	}
+	return c
+}
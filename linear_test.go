@@ -0,0 +1,85 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestLinearEdges downscales a white shape on a transparent background and
+// checks the edge pixels against a reference computed in linear light.
+func TestLinearEdges(t *testing.T) {
+	t.Parallel()
+	// Left half opaque white, right half transparent black, which is what
+	// most encoders store for fully transparent pixels.
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 31; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+		}
+	}
+	for _, r := range []Resampler{Interpolate(Bilinear), Interpolate(Lanczos3), CatmullRom, Lanczos{}} {
+		var (
+			got   = Linear(r).Resize(src, 16, 16)
+			edges int
+		)
+		for x := 0; x < 16; x++ {
+			c := color.NRGBAModel.Convert(got.At(x, 8)).(color.NRGBA)
+			if c.A == 0 || c.A == 0xff {
+				continue
+			}
+			edges++
+			// Premultiplied filtering leaves the colour of the shape intact,
+			// only its coverage changes.
+			if c.R < 0xf8 || c.G < 0xf8 || c.B < 0xf8 {
+				t.Errorf("%T: dark fringe at x=%d: %v", r, x, c)
+			}
+		}
+		if edges == 0 {
+			t.Errorf("%T: no anti-aliased edge pixels found", r)
+		}
+	}
+}
+
+// TestLinearMidtones checks that a fine black and white pattern averages to
+// 50% linear light, which is 188 in sRGB, rather than 128.
+func TestLinearMidtones(t *testing.T) {
+	t.Parallel()
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(0)
+			if (x+y)%2 == 0 {
+				v = 0xff
+			}
+			src.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+	const want = 188
+	got := color.NRGBAModel.Convert(Linear(Interpolate(Bilinear)).Resize(src, 16, 16).At(8, 8)).(color.NRGBA)
+	if d := int(got.R) - want; d < -2 || d > 2 {
+		t.Errorf("want=%d, got=%d", want, got.R)
+	}
+	if got.A != 0xff {
+		t.Errorf("alpha: want=%d, got=%d", 0xff, got.A)
+	}
+}
+
+func TestEncoderLinearLight(t *testing.T) {
+	t.Parallel()
+	iconset, err := NewEncoder(nil).
+		WithAlgorithm(Bilinear).
+		WithLinearLight(true).
+		IconSet(rect(0, 0, 64, 64))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, icon := range iconset.Icons {
+		if icon == nil {
+			continue
+		}
+		if _, ok := icon.Image.(*image.NRGBA); !ok {
+			t.Errorf("%s: want linear light result, got %T", icon.Type.ID, icon.Image)
+		}
+	}
+}
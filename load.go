@@ -0,0 +1,203 @@
+package icns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// Loaded is a decoded source image, normalised for encoding.
+type Loaded struct {
+	Image image.Image
+	// Format is the name the format was registered with, such as "png".
+	Format string
+	// Profile is the embedded ICC profile, nil if there is none.
+	// Profiles of CMYK sources are dropped, since the pixels are converted
+	// to RGB without them.
+	Profile *Profile
+	// ProfileErr reports why an embedded profile could not be used.
+	ProfileErr error
+}
+
+// Load decodes a source image in any format registered with the image
+// package, applying its EXIF orientation and converting CMYK and YCCK JPEGs
+// to RGB, so that it can be given to an Encoder as is.
+//
+// CMYK is converted with the device formula, (1-c)(1-k) and so on, ignoring
+// any embedded CMYK profile, so the colours of CMYK sources are approximate.
+func Load(r io.Reader) (*Loaded, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, patched := withAdobeMarker(data)
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	src := &Loaded{Format: format}
+	if cmyk, ok := img.(*image.CMYK); ok {
+		if patched {
+			// Plain CMYK was read as Adobe's inverted CMYK.
+			for ii := range cmyk.Pix {
+				cmyk.Pix[ii] = 0xff - cmyk.Pix[ii]
+			}
+		}
+		img = cloneImage(cmyk)
+	} else {
+		src.Profile, src.ProfileErr = ExtractProfile(data)
+	}
+	src.Image = orient(img, orientation(data))
+	return src, nil
+}
+
+// withAdobeMarker returns jpeg data for a 4 component image with an Adobe
+// APP14 segment marking it as plain CMYK if it has none, which the jpeg
+// package requires. Other data is returned as is.
+func withAdobeMarker(data []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return data, false
+	}
+	var cmyk, adobe bool
+	jpegSegments(data, func(marker byte, segment []byte) {
+		switch {
+		case marker == 0xee && bytes.HasPrefix(segment, []byte("Adobe")):
+			adobe = true
+		case marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			cmyk = len(segment) > 5 && segment[5] == 4
+		}
+	})
+	if !cmyk || adobe {
+		return data, false
+	}
+	// Version 100, no flags and transform 0 (no colour transform).
+	app14 := []byte{0xff, 0xee, 0x00, 0x0e, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0, 0, 0, 0, 0}
+	patched := make([]byte, 0, len(data)+len(app14))
+	patched = append(patched, data[:2]...)
+	patched = append(patched, app14...)
+	return append(patched, data[2:]...), true
+}
+
+// orientation returns the EXIF orientation of jpeg, png, webp or tiff data,
+// from 1 to 8, or 1 if it has none.
+func orientation(data []byte) int {
+	var exif []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		jpegSegments(data, func(marker byte, segment []byte) {
+			if marker == 0xe1 && exif == nil && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				exif = segment[6:]
+			}
+		})
+	case bytes.HasPrefix(data, pngSignature):
+		pngChunks(data, func(name string, chunk []byte) bool {
+			if name == "eXIf" {
+				exif = chunk
+				return false
+			}
+			return true
+		})
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		for offset := 12; offset+8 <= len(data); {
+			var (
+				size = int(binary.LittleEndian.Uint32(data[offset+4:]))
+				end  = offset + 8 + size
+			)
+			if size < 0 || end > len(data) {
+				break
+			}
+			if string(data[offset:offset+4]) == "EXIF" {
+				exif = bytes.TrimPrefix(data[offset+8:end], []byte("Exif\x00\x00"))
+				break
+			}
+			offset = end + size%2
+		}
+	default:
+		exif = data
+	}
+	return exifOrientation(exif)
+}
+
+// exifOrientation returns the orientation tag of the first IFD of exif, a
+// TIFF structure, or 1 if it is missing or invalid.
+func exifOrientation(exif []byte) int {
+	if len(exif) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(exif[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(exif[4:]))
+	if ifd < 8 || ifd+2 > len(exif) {
+		return 1
+	}
+	count := int(order.Uint16(exif[ifd:]))
+	for ii := 0; ii < count; ii++ {
+		entry := ifd + 2 + ii*12
+		if entry+12 > len(exif) {
+			break
+		}
+		// Orientation is a single SHORT.
+		if order.Uint16(exif[entry:]) == 0x0112 && order.Uint16(exif[entry+2:]) == 3 {
+			if o := int(order.Uint16(exif[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient returns img transformed so that it displays upright, given its EXIF
+// orientation.
+func orient(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+	var (
+		src        = cloneImage(img)
+		pix, depth = samples(src)
+		w, h       = src.Bounds().Dx(), src.Bounds().Dy()
+		dw, dh     = w, h
+	)
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	var dst image.Image = image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	if depth == 2 {
+		dst = image.NewNRGBA64(image.Rect(0, 0, dw, dh))
+	}
+	out, _ := samples(dst)
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// Find the source pixel displayed at x, y.
+			sx, sy := x, y
+			switch o {
+			case 2:
+				sx = w - 1 - x
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sy = h - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			n := 4 * depth
+			copy(out[(y*dw+x)*n:][:n], pix[(sy*w+sx)*n:])
+		}
+	}
+	return dst
+}
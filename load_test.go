@@ -0,0 +1,222 @@
+package icns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestOrientation(t *testing.T) {
+	t.Parallel()
+	var (
+		exif = exifWithOrientation(6)
+		src  = image.NewNRGBA(image.Rect(0, 0, 16, 8))
+		pngb bytes.Buffer
+		jpgb bytes.Buffer
+	)
+	if err := png.Encode(&pngb, src); err != nil {
+		t.Fatalf("encoding png: %v", err)
+	}
+	if err := jpeg.Encode(&jpgb, src, nil); err != nil {
+		t.Fatalf("encoding jpeg: %v", err)
+	}
+	var (
+		app1 = append([]byte{0xff, 0xe1, 0, 0}, append([]byte("Exif\x00\x00"), exif...)...)
+		jpg  = append(append(append([]byte{}, jpgb.Bytes()[:2]...), app1...), jpgb.Bytes()[2:]...)
+		webp = append([]byte("RIFF\x00\x00\x00\x00WEBPEXIF"), 0, 0, 0, 0)
+	)
+	binary.BigEndian.PutUint16(jpg[4:], uint16(len(app1)-2))
+	binary.LittleEndian.PutUint32(webp[16:], uint32(len(exif)))
+	webp = append(webp, exif...)
+	tests := []struct {
+		desc string
+		data []byte
+		want int
+	}{
+		{"jpeg", jpg, 6},
+		{"png", withChunk(pngb.Bytes(), "eXIf", exif), 6},
+		{"webp", webp, 6},
+		{"tiff", exif, 6},
+		{"none", pngb.Bytes(), 1},
+		{"invalid", exifWithOrientation(9), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			if got := orientation(tt.data); got != tt.want {
+				st.Errorf("want orientation %d, got %d", tt.want, got)
+			}
+		})
+	}
+	t.Run("load", func(st *testing.T) {
+		for _, data := range [][]byte{jpg, withChunk(pngb.Bytes(), "eXIf", exif)} {
+			source, err := Load(bytes.NewReader(data))
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			if got := source.Image.Bounds(); got != image.Rect(0, 0, 8, 16) {
+				st.Errorf("%s: want upright 8x16, got %v", source.Format, got)
+			}
+		}
+	})
+}
+
+func TestOrient(t *testing.T) {
+	t.Parallel()
+	// Source pixels are labelled by their red channel:
+	//
+	//	a b c
+	//	d e f
+	const a, b, c, d, e, f = 1, 2, 3, 4, 5, 6
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for ii, v := range []uint8{a, b, c, d, e, f} {
+		src.SetNRGBA(ii%3, ii/3, color.NRGBA{R: v, A: 0xff})
+	}
+	tests := []struct {
+		orientation int
+		want        [][]uint8
+	}{
+		{1, [][]uint8{{a, b, c}, {d, e, f}}},
+		{2, [][]uint8{{c, b, a}, {f, e, d}}},
+		{3, [][]uint8{{f, e, d}, {c, b, a}}},
+		{4, [][]uint8{{d, e, f}, {a, b, c}}},
+		{5, [][]uint8{{a, d}, {b, e}, {c, f}}},
+		{6, [][]uint8{{d, a}, {e, b}, {f, c}}},
+		{7, [][]uint8{{f, c}, {e, b}, {d, a}}},
+		{8, [][]uint8{{c, f}, {b, e}, {a, d}}},
+	}
+	for _, tt := range tests {
+		got := orient(src, tt.orientation)
+		for y, row := range tt.want {
+			for x, want := range row {
+				if r := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA).R; r != want {
+					t.Errorf("orientation %d: want %d at %d,%d, got %d", tt.orientation, want, x, y, r)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadCMYK(t *testing.T) {
+	t.Parallel()
+	ink := color.CMYK{C: 0x20, M: 0xc0, Y: 0x80, K: 0x10}
+	r, g, b := color.CMYKToRGB(ink.C, ink.M, ink.Y, ink.K)
+	want := color.NRGBA{r, g, b, 0xff}
+	for _, adobe := range []bool{false, true} {
+		source, err := Load(bytes.NewReader(cmykJPEG(ink, adobe)))
+		if err != nil {
+			t.Fatalf("adobe %t: unexpected error: %v", adobe, err)
+		}
+		got := color.NRGBAModel.Convert(source.Image.At(4, 4)).(color.NRGBA)
+		if !near(got, want, 2) {
+			t.Errorf("adobe %t: want %v, got %v", adobe, want, got)
+		}
+		if _, ok := source.Image.(*image.CMYK); ok {
+			t.Errorf("adobe %t: want rgb image, got cmyk", adobe)
+		}
+	}
+}
+
+// near reports whether each channel of a and b differs by at most tolerance.
+func near(a, b color.NRGBA, tolerance int) bool {
+	for _, d := range []int{
+		int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B), int(a.A) - int(b.A),
+	} {
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// exifWithOrientation returns a big endian TIFF structure holding only the
+// orientation tag.
+func exifWithOrientation(o uint16) []byte {
+	exif := []byte("MM\x00*\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry, 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], o)
+	return append(append(exif, entry...), 0, 0, 0, 0)
+}
+
+// withChunk returns png data with a chunk inserted after IHDR.
+func withChunk(data []byte, name string, chunk []byte) []byte {
+	var (
+		at  = len(pngSignature) + 8 + 13 + 4
+		buf = append([]byte{}, data[:at]...)
+	)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(chunk)))
+	buf = append(append(buf, name...), chunk...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(append([]byte(name), chunk...)))
+	return append(buf, data[at:]...)
+}
+
+// cmykJPEG returns a baseline 8x8 jpeg of a single CMYK colour. Every block
+// holds only a DC coefficient, with a quantisation of 1, so the colour
+// survives exactly. Adobe files store ink inverted.
+func cmykJPEG(ink color.CMYK, adobe bool) []byte {
+	var (
+		buf     = []byte{0xff, 0xd8}
+		segment = func(marker byte, payload ...byte) {
+			buf = append(buf, 0xff, marker)
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(payload)+2))
+			buf = append(buf, payload...)
+		}
+		ones = bytes.Repeat([]byte{1}, 64)
+	)
+	if adobe {
+		segment(0xee, 'A', 'd', 'o', 'b', 'e', 0, 0x64, 0, 0, 0, 0, 0)
+	}
+	segment(0xdb, append([]byte{0}, ones...)...)
+	segment(0xc0, 8, 0, 8, 0, 8, 4, 1, 0x11, 0, 2, 0x11, 0, 3, 0x11, 0, 4, 0x11, 0)
+	// DC categories 0 to 11 as 4 bit codes, and a lone end of block code.
+	dc := append([]byte{0x00, 0, 0, 0, 12}, make([]byte, 12)...)
+	for ii := 0; ii < 12; ii++ {
+		dc = append(dc, byte(ii))
+	}
+	segment(0xc4, dc...)
+	segment(0xc4, append([]byte{0x10, 1}, append(make([]byte, 15), 0x00)...)...)
+	segment(0xda, 4, 1, 0, 2, 0, 3, 0, 4, 0, 0, 63, 0)
+	var (
+		acc   uint32
+		nbits uint
+		bits  []byte
+		write = func(v uint32, n uint) {
+			acc, nbits = acc<<n|v&(1<<n-1), nbits+n
+			for nbits >= 8 {
+				b := byte(acc >> (nbits - 8))
+				bits = append(bits, b)
+				if b == 0xff {
+					bits = append(bits, 0)
+				}
+				nbits -= 8
+			}
+		}
+	)
+	for _, v := range []uint8{ink.C, ink.M, ink.Y, ink.K} {
+		if adobe {
+			v = 0xff - v
+		}
+		// The DC coefficient is 8 times the level shifted sample.
+		diff := 8 * (int(v) - 128)
+		var category uint
+		for 1<<category <= abs(diff) {
+			category++
+		}
+		write(uint32(category), 4)
+		if diff < 0 {
+			diff += 1<<category - 1
+		}
+		write(uint32(diff), category)
+		write(0, 1)
+	}
+	write(0xff, 7)
+	buf = append(buf, bits...)
+	return append(buf, 0xff, 0xd9)
+}
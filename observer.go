@@ -0,0 +1,88 @@
+package icns
+
+import (
+	"sync"
+	"time"
+)
+
+// Observer receives progress events while encoding or decoding.
+// Calls are serialised, so an Observer need not be safe for concurrent use.
+type Observer func(Event)
+
+// EventKind identifies what an Event reports.
+type EventKind int
+
+// EventKind constants.
+const (
+	// EntryStarted reports that work on an icon has started.
+	EntryStarted EventKind = iota
+	// EntryFinished reports that an icon is done.
+	EntryFinished
+	// Finished reports that every icon is done, carrying the totals.
+	Finished
+)
+
+// Event describes the progress of an encode or decode.
+type Event struct {
+	Kind EventKind
+	// Type of the icon, for entry events.
+	Type OsType
+	// Resize is how long the icon took to resize, for EntryFinished events
+	// while encoding.
+	Resize time.Duration
+	// Bytes is the size of the icon's image data, for EntryFinished events.
+	Bytes int
+	// Stats summarises the whole encode or decode, for Finished events.
+	Stats *Stats
+}
+
+// Stats summarises an encode or decode.
+type Stats struct {
+	// Entries is the number of icons.
+	Entries int
+	// Upscaled is the number of icons upscaled from the source.
+	Upscaled int
+	// Bytes is the total size of the icons' image data.
+	Bytes int
+	// Sizes is the size of the image data for each OsType ID.
+	Sizes map[string]int
+	// Resize is the total time spent resizing, summed across goroutines.
+	Resize time.Duration
+	// Encode is the total time spent encoding, summed across goroutines.
+	Encode time.Duration
+	// Decode is the total time spent decoding.
+	Decode time.Duration
+	// Elapsed is the wall time taken.
+	Elapsed time.Duration
+}
+
+// add records an icon of the given type and size in the totals.
+func (s *Stats) add(t OsType, bytes int) {
+	if s.Sizes == nil {
+		s.Sizes = map[string]int{}
+	}
+	s.Entries++
+	s.Bytes += bytes
+	s.Sizes[t.ID] += bytes
+}
+
+// finishDecode records the time taken to decode since start.
+func (s *Stats) finishDecode(start time.Time) {
+	s.Decode = time.Since(start)
+	s.Elapsed = s.Decode
+}
+
+// notifier serialises calls to an optional Observer.
+type notifier struct {
+	mu       sync.Mutex
+	observer Observer
+}
+
+func (n *notifier) notify(e Event) {
+	if n.observer == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.observer(e)
+}
@@ -0,0 +1,94 @@
+package icns
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+	var (
+		buf    = bytes.NewBuffer(nil)
+		events = map[EventKind]int{}
+		total  int
+		stats  *Stats
+	)
+	enc := NewEncoder(buf).
+		WithAlgorithm(NearestNeighbor).
+		WithObserver(func(e Event) {
+			events[e.Kind]++
+			switch e.Kind {
+			case EntryFinished:
+				total += e.Bytes
+			case Finished:
+				stats = e.Stats
+			}
+		})
+	if err := enc.Encode(rect(0, 0, 300, 300)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if events[EntryStarted] != 5 || events[EntryFinished] != 5 || events[Finished] != 1 {
+		t.Errorf("events: want 5 started, 5 finished and 1 done, got %v", events)
+	}
+	if stats == nil {
+		t.Fatalf("want stats")
+	}
+	if stats.Entries != 5 {
+		t.Errorf("entries: want=5, got=%d", stats.Entries)
+	}
+	if stats.Bytes != total {
+		t.Errorf("bytes: want=%d, got=%d", total, stats.Bytes)
+	}
+	if stats.Sizes["ic13"] == 0 || stats.Sizes["ic13"] != stats.Sizes["ic08"] {
+		t.Errorf("want equal, non-zero sizes for shared 256px icons, got %v", stats.Sizes)
+	}
+	if stats.Elapsed <= 0 {
+		t.Errorf("want elapsed time")
+	}
+
+	var (
+		data    = buf.Bytes()
+		decoded *Stats
+	)
+	events = map[EventKind]int{}
+	images, err := NewDecoder(bytes.NewReader(data)).
+		WithObserver(func(e Event) {
+			events[e.Kind]++
+			if e.Kind == Finished {
+				decoded = e.Stats
+			}
+		}).
+		DecodeAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(images) != 5 || events[EntryFinished] != 5 || events[Finished] != 1 {
+		t.Errorf("decode events: want 5 images and entries, got %d images and %v", len(images), events)
+	}
+	if decoded == nil || decoded.Bytes != stats.Bytes {
+		t.Errorf("decoded stats: want %d bytes, got %+v", stats.Bytes, decoded)
+	}
+
+	events = map[EventKind]int{}
+	set, err := NewDecoder(bytes.NewReader(data)).
+		WithObserver(func(e Event) { events[e.Kind]++ }).
+		DecodeIconSet()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if events[Finished] != 1 {
+		t.Errorf("decode icon set events: want 1 done, got %v", events)
+	}
+	if set.Stats.Entries != 5 || set.Stats.Bytes != stats.Bytes || set.Stats.Decode <= 0 {
+		t.Errorf("icon set stats: want 5 entries of %d bytes, got %+v", stats.Bytes, set.Stats)
+	}
+	events = map[EventKind]int{}
+	if _, err := NewDecoder(bytes.NewReader(data)).
+		WithObserver(func(e Event) { events[e.Kind]++ }).
+		Decode(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if events[EntryFinished] != 1 || events[Finished] != 1 {
+		t.Errorf("decode largest events: want 1 entry and 1 done, got %v", events)
+	}
+}
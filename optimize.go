@@ -0,0 +1,314 @@
+package icns
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// optimizePNG losslessly encodes img as the smallest png found by trying
+// colour type reductions, filter strategies and compression levels, falling
+// back to the standard encoder's output if nothing beats it.
+// 16 bit images are left to the standard encoder.
+func optimizePNG(img image.Image) ([]byte, error) {
+	best, err := encodeImage(img)
+	if err != nil {
+		return nil, err
+	}
+	if is16Bit(img) {
+		return best, nil
+	}
+	src := toNRGBA(img)
+	for _, l := range layouts(src) {
+		var (
+			bestFilter = -1
+			bestSize   = 0
+			filtered   = make([][]byte, len(filters))
+		)
+		for ii, f := range filters {
+			filtered[ii] = l.filter(f)
+			data, err := deflate(filtered[ii], zlib.BestCompression)
+			if err != nil {
+				return nil, err
+			}
+			if bestFilter < 0 || len(data) < bestSize {
+				bestFilter, bestSize = ii, len(data)
+			}
+		}
+		for _, level := range []int{zlib.BestCompression, zlib.DefaultCompression} {
+			data, err := deflate(filtered[bestFilter], level)
+			if err != nil {
+				return nil, err
+			}
+			if candidate := l.png(data); len(candidate) < len(best) {
+				best = candidate
+			}
+		}
+	}
+	return best, nil
+}
+
+// PNG colour types.
+const (
+	ctGray      = 0
+	ctTrueColor = 2
+	ctPaletted  = 3
+	ctGrayAlpha = 4
+	ctTrueAlpha = 6
+)
+
+// pngLayout is an image packed into unfiltered png scanlines.
+type pngLayout struct {
+	width, height int
+	colorType     uint8
+	depth         uint8
+	// bpp is the filter distance: bytes per pixel, rounded up to one.
+	bpp     int
+	rows    [][]byte
+	palette []color.NRGBA
+}
+
+// layouts returns every lossless packing worth trying for img.
+func layouts(img *image.NRGBA) []pngLayout {
+	var (
+		w, h   = img.Rect.Dx(), img.Rect.Dy()
+		opaque = true
+		gray   = true
+		colors = map[color.NRGBA]int{}
+	)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.NRGBAAt(x, y)
+			opaque = opaque && c.A == 0xff
+			gray = gray && c.R == c.G && c.G == c.B
+			if len(colors) <= 256 {
+				colors[c]++
+			}
+		}
+	}
+	var out []pngLayout
+	pack := func(colorType uint8, bpp int, px func(c color.NRGBA, dst []byte)) {
+		l := pngLayout{width: w, height: h, colorType: colorType, depth: 8, bpp: bpp}
+		for y := 0; y < h; y++ {
+			row := make([]byte, w*bpp)
+			for x := 0; x < w; x++ {
+				px(img.NRGBAAt(x, y), row[x*bpp:])
+			}
+			l.rows = append(l.rows, row)
+		}
+		out = append(out, l)
+	}
+	if len(colors) <= 256 {
+		out = append(out, paletted(img, colors))
+	}
+	switch {
+	case gray && opaque:
+		pack(ctGray, 1, func(c color.NRGBA, dst []byte) { dst[0] = c.R })
+	case gray:
+		pack(ctGrayAlpha, 2, func(c color.NRGBA, dst []byte) { dst[0], dst[1] = c.R, c.A })
+	case opaque:
+		pack(ctTrueColor, 3, func(c color.NRGBA, dst []byte) { dst[0], dst[1], dst[2] = c.R, c.G, c.B })
+	default:
+		pack(ctTrueAlpha, 4, func(c color.NRGBA, dst []byte) { dst[0], dst[1], dst[2], dst[3] = c.R, c.G, c.B, c.A })
+	}
+	return out
+}
+
+// paletted packs img into the smallest bit depth that indexes colors.
+func paletted(img *image.NRGBA, colors map[color.NRGBA]int) pngLayout {
+	var (
+		w, h    = img.Rect.Dx(), img.Rect.Dy()
+		palette = make([]color.NRGBA, 0, len(colors))
+	)
+	for c := range colors {
+		palette = append(palette, c)
+	}
+	// Translucent entries go first so that the tRNS chunk can stop at the
+	// last of them. The rest are ordered by frequency for stable output.
+	sort.Slice(palette, func(ii, jj int) bool {
+		left, right := palette[ii], palette[jj]
+		if (left.A == 0xff) != (right.A == 0xff) {
+			return left.A != 0xff
+		}
+		if colors[left] != colors[right] {
+			return colors[left] > colors[right]
+		}
+		return packed(left) < packed(right)
+	})
+	index := make(map[color.NRGBA]int, len(palette))
+	for ii, c := range palette {
+		index[c] = ii
+	}
+	depth := uint8(8)
+	switch {
+	case len(palette) <= 2:
+		depth = 1
+	case len(palette) <= 4:
+		depth = 2
+	case len(palette) <= 16:
+		depth = 4
+	}
+	var (
+		perByte = 8 / int(depth)
+		l       = pngLayout{width: w, height: h, colorType: ctPaletted, depth: depth, bpp: 1, palette: palette}
+	)
+	for y := 0; y < h; y++ {
+		row := make([]byte, (w+perByte-1)/perByte)
+		for x := 0; x < w; x++ {
+			shift := uint(8 - int(depth)*(x%perByte+1))
+			row[x/perByte] |= byte(index[img.NRGBAAt(x, y)]) << shift
+		}
+		l.rows = append(l.rows, row)
+	}
+	return l
+}
+
+func packed(c color.NRGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
+
+// Filter strategies: the five png filter types applied to every row, and
+// the adaptive heuristic that picks one per row.
+const adaptive = 5
+
+var filters = []int{0, 1, 2, 3, 4, adaptive}
+
+// filter returns the scanlines filtered with the given strategy, each row
+// prefixed by its filter type.
+func (l pngLayout) filter(strategy int) []byte {
+	var (
+		stride = len(l.rows[0])
+		out    = make([]byte, 0, (stride+1)*len(l.rows))
+		prev   = make([]byte, stride)
+		row    = make([]byte, stride)
+	)
+	for _, cur := range l.rows {
+		ft := strategy
+		if strategy == adaptive {
+			best := -1
+			for candidate := 0; candidate < 5; candidate++ {
+				filterRow(candidate, cur, prev, l.bpp, row)
+				var sum int
+				for _, b := range row {
+					sum += abs(int(int8(b)))
+				}
+				if best < 0 || sum < best {
+					best, ft = sum, candidate
+				}
+			}
+		}
+		filterRow(ft, cur, prev, l.bpp, row)
+		out = append(out, byte(ft))
+		out = append(out, row...)
+		prev = cur
+	}
+	return out
+}
+
+func filterRow(ft int, cur, prev []byte, bpp int, dst []byte) {
+	for ii := range cur {
+		var a, b, c byte
+		if ii >= bpp {
+			a, c = cur[ii-bpp], prev[ii-bpp]
+		}
+		b = prev[ii]
+		switch ft {
+		case 0:
+			dst[ii] = cur[ii]
+		case 1:
+			dst[ii] = cur[ii] - a
+		case 2:
+			dst[ii] = cur[ii] - b
+		case 3:
+			dst[ii] = cur[ii] - byte((int(a)+int(b))/2)
+		case 4:
+			dst[ii] = cur[ii] - paeth(a, b, c)
+		}
+	}
+}
+
+func paeth(a, b, c byte) byte {
+	var (
+		p  = int(a) + int(b) - int(c)
+		pa = abs(p - int(a))
+		pb = abs(p - int(b))
+		pc = abs(p - int(c))
+	)
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func deflate(data []byte, level int) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	zw, err := zlib.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// png assembles a png file from the compressed image data.
+func (l pngLayout) png(idat []byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(pngSignature)
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(l.width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(l.height))
+	ihdr[8], ihdr[9] = l.depth, l.colorType
+	writeChunk(buf, "IHDR", ihdr)
+	if l.colorType == ctPaletted {
+		var (
+			plte = make([]byte, 0, len(l.palette)*3)
+			trns []byte
+		)
+		for _, c := range l.palette {
+			plte = append(plte, c.R, c.G, c.B)
+			if c.A != 0xff {
+				trns = append(trns, c.A)
+			}
+		}
+		writeChunk(buf, "PLTE", plte)
+		if len(trns) > 0 {
+			writeChunk(buf, "tRNS", trns)
+		}
+	}
+	writeChunk(buf, "IDAT", idat)
+	writeChunk(buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func writeChunk(buf *bytes.Buffer, name string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], name)
+	buf.Write(header[:])
+	buf.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,112 @@
+package icns
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestOptimizePNG(t *testing.T) {
+	t.Parallel()
+	fill := func(f func(x, y int) color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 37, 29))
+		for y := 0; y < 29; y++ {
+			for x := 0; x < 37; x++ {
+				img.SetNRGBA(x, y, f(x, y))
+			}
+		}
+		return img
+	}
+	tests := []struct {
+		desc string
+		img  *image.NRGBA
+	}{
+		{"two colours", fill(func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x%2) * 0xff, A: 0xff}
+		})},
+		{"sixteen colours with alpha", fill(func(x, y int) color.NRGBA {
+			return color.NRGBA{G: uint8(x % 16 * 16), A: uint8(y%2) * 0xff}
+		})},
+		{"many colours", fill(func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 7), G: uint8(y * 5), B: uint8(x ^ y), A: uint8(x * y)}
+		})},
+		{"opaque true colour", fill(func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 7), G: uint8(y * 5), B: uint8(x ^ y), A: 0xff}
+		})},
+		{"opaque gray", fill(func(x, y int) color.NRGBA {
+			v := uint8(x*7 + y)
+			return color.NRGBA{R: v, G: v, B: v, A: 0xff}
+		})},
+		{"gray with alpha", fill(func(x, y int) color.NRGBA {
+			v := uint8(x*7 + y)
+			return color.NRGBA{R: v, G: v, B: v, A: uint8(y * 9)}
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			data, err := optimizePNG(tt.img)
+			if err != nil {
+				st.Fatalf("optimizing: %v", err)
+			}
+			standard, err := encodeImage(tt.img)
+			if err != nil {
+				st.Fatalf("encoding: %v", err)
+			}
+			if len(data) > len(standard) {
+				st.Errorf("want at most %d bytes, got %d", len(standard), len(data))
+			}
+			got, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				st.Fatalf("decoding: %v", err)
+			}
+			for y := 0; y < 29; y++ {
+				for x := 0; x < 37; x++ {
+					want := tt.img.NRGBAAt(x, y)
+					if c := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA); c != want {
+						st.Fatalf("lossy at (%d,%d): want=%v, got=%v", x, y, want, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSizeBudget(t *testing.T) {
+	t.Parallel()
+	iconset, err := NewEncoder(nil).
+		WithAlgorithm(NearestNeighbor).
+		WithOptimize(true).
+		IconSet(benchSource())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var size int
+	for _, icon := range iconset.Icons {
+		size += len(icon.data) + 8
+	}
+	budget := size - 1
+	over, err := NewEncoder(nil).
+		WithAlgorithm(NearestNeighbor).
+		WithOptimize(true).
+		WithSizeBudget(budget).
+		IconSet(benchSource())
+	var overErr ErrSizeBudget
+	if !errors.As(err, &overErr) {
+		t.Fatalf("want ErrSizeBudget, got %v", err)
+	}
+	if over == nil || len(over.Icons) != len(iconset.Icons) {
+		t.Errorf("want the icon set returned with the error, got %v", over)
+	}
+	if len(overErr.Over) != 1 || overErr.Over[0].Type.ID != "ic10" {
+		t.Errorf("want the 1024px icon reported, got %+v", overErr.Over)
+	}
+	if _, err := NewEncoder(nil).
+		WithAlgorithm(NearestNeighbor).
+		WithSizeBudget(1 << 30).
+		IconSet(rect(0, 0, 64, 64)); err != nil {
+		t.Errorf("unexpected error within budget: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package icns
+
+import (
+	"image"
+	"image/draw"
+)
+
+// PixelArt is a Resampler for pixel art sources.
+// It only scales by whole factors using nearest neighbour, so every source
+// pixel becomes an equally sized block, and centres the result, padding the
+// remainder with transparent pixels.
+type PixelArt struct {
+	// EdgeDirected upscales with repeated Scale2x (EPX) passes where the factor
+	// allows it, which rounds off diagonal steps while keeping edges hard.
+	EdgeDirected bool
+}
+
+// Resize scales img by the largest whole factor that fits width x height.
+func (p PixelArt) Resize(img image.Image, width, height uint) image.Image {
+	var (
+		src  = toNRGBA(img)
+		sw   = src.Rect.Dx()
+		sh   = src.Rect.Dy()
+		w, h = int(width), int(height)
+		dst  = image.NewNRGBA(image.Rect(0, 0, w, h))
+	)
+	if sw == 0 || sh == 0 || w == 0 || h == 0 {
+		return dst
+	}
+	var scaled *image.NRGBA
+	if sw <= w && sh <= h {
+		factor := w / sw
+		if h/sh < factor {
+			factor = h / sh
+		}
+		scaled = src
+		for p.EdgeDirected && factor%2 == 0 {
+			scaled = scale2x(scaled)
+			factor /= 2
+		}
+		scaled = nearest(scaled, factor, 1)
+	} else {
+		divisor := (sw + w - 1) / w
+		if d := (sh + h - 1) / h; d > divisor {
+			divisor = d
+		}
+		scaled = nearest(src, 1, divisor)
+	}
+	b := scaled.Bounds()
+	offset := image.Pt((w-b.Dx())/2, (h-b.Dy())/2)
+	draw.Draw(dst, b.Add(offset), scaled, b.Min, draw.Src)
+	return dst
+}
+
+// nearest scales src by mul/div using nearest neighbour.
+// Downscales sample the centre of each div by div block.
+func nearest(src *image.NRGBA, mul, div int) *image.NRGBA {
+	if mul == 1 && div == 1 {
+		return src
+	}
+	var (
+		w   = src.Rect.Dx() * mul / div
+		h   = src.Rect.Dy() * mul / div
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+	)
+	for y := 0; y < h; y++ {
+		sy := (y*div + div/2) / mul
+		for x := 0; x < w; x++ {
+			sx := (x*div + div/2) / mul
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// scale2x doubles src using the Scale2x (EPX) edge-directed algorithm.
+func scale2x(src *image.NRGBA) *image.NRGBA {
+	var (
+		w, h = src.Rect.Dx(), src.Rect.Dy()
+		dst  = image.NewNRGBA(image.Rect(0, 0, w*2, h*2))
+		at   = func(x, y int) []uint8 {
+			x, y = clampInt(x, 0, w-1), clampInt(y, 0, h-1)
+			return src.Pix[src.PixOffset(x, y):][:4]
+		}
+		same = func(a, b []uint8) bool {
+			return a[0] == b[0] && a[1] == b[1] && a[2] == b[2] && a[3] == b[3]
+		}
+	)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var (
+				p     = at(x, y)
+				up    = at(x, y-1)
+				right = at(x+1, y)
+				left  = at(x-1, y)
+				down  = at(x, y+1)
+				e     = [4][]uint8{p, p, p, p}
+			)
+			if !same(up, down) && !same(left, right) {
+				if same(left, up) {
+					e[0] = up
+				}
+				if same(up, right) {
+					e[1] = right
+				}
+				if same(left, down) {
+					e[2] = left
+				}
+				if same(down, right) {
+					e[3] = down
+				}
+			}
+			copy(dst.Pix[dst.PixOffset(x*2, y*2):], e[0])
+			copy(dst.Pix[dst.PixOffset(x*2+1, y*2):], e[1])
+			copy(dst.Pix[dst.PixOffset(x*2, y*2+1):], e[2])
+			copy(dst.Pix[dst.PixOffset(x*2+1, y*2+1):], e[3])
+		}
+	}
+	return dst
+}
+
+// toNRGBA returns img as an *image.NRGBA with its origin at (0, 0),
+// converting it if required.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if src, ok := img.(*image.NRGBA); ok && src.Rect.Min == (image.Point{}) {
+		return src
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA(b.Sub(b.Min))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
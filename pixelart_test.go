@@ -0,0 +1,115 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPixelArt(t *testing.T) {
+	t.Parallel()
+	var (
+		black = color.NRGBA{A: 0xff}
+		white = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	)
+	checker := func(size int) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, size, size))
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				c := black
+				if (x+y)%2 == 0 {
+					c = white
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+		return img
+	}
+	tests := []struct {
+		desc   string
+		src    *image.NRGBA
+		size   uint
+		block  int
+		offset int
+	}{
+		{"exact factor", checker(32), 1024, 32, 0},
+		{"padded factor", checker(24), 1024, 42, 8},
+		{"downscale by whole divisor", checker(64), 16, 0, 0},
+		{"padded downscale", checker(48), 32, 0, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			got := PixelArt{}.Resize(tt.src, tt.size, tt.size).(*image.NRGBA)
+			if got.Rect.Dx() != int(tt.size) || got.Rect.Dy() != int(tt.size) {
+				st.Fatalf("bounds: want %dx%d, got %v", tt.size, tt.size, got.Rect)
+			}
+			if tt.offset > 0 {
+				if a := got.NRGBAAt(tt.offset-1, tt.offset-1).A; a != 0 {
+					st.Errorf("want transparent padding, got alpha=%d", a)
+				}
+			}
+			if a := got.NRGBAAt(tt.offset, tt.offset).A; a != 0xff {
+				st.Errorf("want artwork at offset %d, got alpha=%d", tt.offset, a)
+			}
+			if tt.block == 0 {
+				return
+			}
+			// Every source pixel is an equally sized block.
+			for y := 0; y < tt.src.Rect.Dy(); y++ {
+				for x := 0; x < tt.src.Rect.Dx(); x++ {
+					want := tt.src.NRGBAAt(x, y)
+					for _, d := range []int{0, tt.block - 1} {
+						px := tt.offset + x*tt.block + d
+						py := tt.offset + y*tt.block + d
+						if c := got.NRGBAAt(px, py); c != want {
+							st.Fatalf("pixel (%d,%d) at (%d,%d): want=%v, got=%v", x, y, px, py, want, c)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestScale2x(t *testing.T) {
+	t.Parallel()
+	var (
+		black = color.NRGBA{A: 0xff}
+		white = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		src   = image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	)
+	// A diagonal step: the top and left rows are black, the rest white.
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			c := white
+			if x == 0 || y == 0 {
+				c = black
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+	got := scale2x(src)
+	if got.Rect.Dx() != 6 || got.Rect.Dy() != 6 {
+		t.Fatalf("bounds: want 6x6, got %v", got.Rect)
+	}
+	// The inside corner of the step is rounded off.
+	if c := got.NRGBAAt(2, 2); c != black {
+		t.Errorf("corner: want=%v, got=%v", black, c)
+	}
+	if c := got.NRGBAAt(3, 3); c != white {
+		t.Errorf("body: want=%v, got=%v", white, c)
+	}
+}
+
+func TestEncoderPixelArt(t *testing.T) {
+	t.Parallel()
+	iconset, err := NewEncoder(nil).WithPixelArt(true).IconSet(image.NewNRGBA(image.Rect(0, 0, 32, 32)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, icon := range iconset.Icons {
+		if icon == nil {
+			t.Fatalf("want every size filled")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package placeholder renders simple generated icons: centred initials or
+// short text on a rounded square, for tools and prototypes without artwork.
+package placeholder
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/jackmordaunt/icns/v3/internal/paint"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// Icon is a placeholder icon that renders at any size.
+// It implements icns.SourceProvider.
+type Icon struct {
+	// Text is drawn centred in Go Bold, shrunk to fit if it is long.
+	Text string
+	// Background fills the rounded square. Defaults to grey if nil.
+	Background color.Color
+	// Foreground is the colour of the text. If nil, black or white is used,
+	// whichever contrasts more with the background.
+	Foreground color.Color
+}
+
+const (
+	// radius of the corners, as a fraction of the side.
+	radius = 0.225
+	// capHeight of the text, as a fraction of the side.
+	capHeight = 0.36
+	// width is the widest the text may be, as a fraction of the side.
+	width = 0.76
+)
+
+var defaultBackground = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+// Image renders the icon as a size by size image.
+func (icon Icon) Image(size uint) (image.Image, error) {
+	var (
+		side = int(size)
+		dst  = image.NewNRGBA(image.Rect(0, 0, side, side))
+		bg   = icon.Background
+		fg   = icon.Foreground
+	)
+	if bg == nil {
+		bg = defaultBackground
+	}
+	if fg == nil {
+		fg = Contrasting(bg)
+	}
+	draw.DrawMask(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, paint.RoundedRect(side, side, float64(side)*radius), image.Point{}, draw.Over)
+	if icon.Text == "" {
+		return dst, nil
+	}
+	face, err := fit(icon.Text, float64(size))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		advance = font.MeasureString(face, icon.Text)
+		caps    = face.Metrics().CapHeight
+		d       = font.Drawer{Dst: dst, Src: image.NewUniform(fg), Face: face}
+	)
+	d.Dot = fixed.Point26_6{
+		X: (fixed.I(side) - advance) / 2,
+		Y: (fixed.I(side) + caps) / 2,
+	}
+	d.DrawString(icon.Text)
+	return dst, nil
+}
+
+// Contrasting returns black or white, whichever has the greater WCAG
+// contrast ratio against c.
+func Contrasting(c color.Color) color.Color {
+	// Against black the ratio is (l+0.05)/0.05, and against white it is
+	// 1.05/(l+0.05); they are equal where l is about 0.179.
+	if luminance(c) > 0.179 {
+		return color.Black
+	}
+	return color.White
+}
+
+// luminance returns the WCAG relative luminance of c.
+func luminance(c color.Color) float64 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	linear := func(v uint8) float64 {
+		return paint.SRGBToLinear(float64(v) / 0xff)
+	}
+	return 0.2126*linear(n.R) + 0.7152*linear(n.G) + 0.0722*linear(n.B)
+}
+
+// fit returns Go Bold at the standard cap height for an icon of the given
+// side, shrunk so that text fits within the allowed width.
+func fit(text string, side float64) (font.Face, error) {
+	caps := side * capHeight
+	face, err := paint.Bold(caps)
+	if err != nil {
+		return nil, err
+	}
+	advance := float64(font.MeasureString(face, text)) / 64
+	if max := side * width; advance > max {
+		return paint.Bold(caps * max / advance)
+	}
+	return face, nil
+}
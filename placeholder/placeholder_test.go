@@ -0,0 +1,91 @@
+package placeholder
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/jackmordaunt/icns/v3"
+)
+
+func TestContrasting(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		bg   color.Color
+		want color.Color
+	}{
+		{color.NRGBA{R: 0x33, G: 0x66, B: 0xff, A: 0xff}, color.White},
+		{color.NRGBA{R: 0xff, G: 0xdd, B: 0x00, A: 0xff}, color.Black},
+		{color.Black, color.White},
+		{color.White, color.Black},
+	}
+	for _, tt := range tests {
+		if got := Contrasting(tt.bg); got != tt.want {
+			t.Errorf("%v: want=%v, got=%v", tt.bg, tt.want, got)
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	t.Parallel()
+	var (
+		bg = color.NRGBA{R: 0x33, G: 0x66, B: 0xff, A: 0xff}
+		fg = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	)
+	// columns returns the leftmost and rightmost columns holding text.
+	columns := func(img image.Image) (int, int) {
+		left, right := -1, -1
+		b := img.Bounds()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				if color.NRGBAModel.Convert(img.At(x, y)) == fg {
+					if left < 0 {
+						left = x
+					}
+					right = x
+					break
+				}
+			}
+		}
+		return left, right
+	}
+	for _, tt := range []struct {
+		text string
+		size uint
+	}{
+		{"QA", 32},
+		{"QA", 512},
+		{"Internal Tools", 256},
+	} {
+		img, err := Icon{Text: tt.text, Background: bg}.Image(tt.size)
+		if err != nil {
+			t.Fatalf("%q at %dpx: %v", tt.text, tt.size, err)
+		}
+		side := int(tt.size)
+		if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+			t.Errorf("%q at %dpx: want rounded corner, got alpha %d", tt.text, tt.size, a)
+		}
+		if c := color.NRGBAModel.Convert(img.At(side/2, 1)); c != bg {
+			t.Errorf("%q at %dpx: want background at edge, got %v", tt.text, tt.size, c)
+		}
+		left, right := columns(img)
+		if left < 0 {
+			t.Fatalf("%q at %dpx: want text drawn in white", tt.text, tt.size)
+		}
+		if margin := side / 10; left < margin || right >= side-margin {
+			t.Errorf("%q at %dpx: want text within the margins, got columns %d to %d", tt.text, tt.size, left, right)
+		}
+		if centre := (left + right) / 2; centre < side/2-side/16 || centre > side/2+side/16 {
+			t.Errorf("%q at %dpx: want text centred, got centre %d", tt.text, tt.size, centre)
+		}
+	}
+	iconset, err := icns.NewEncoder(nil).IconSetSource(Icon{Text: "QA", Background: bg})
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	for _, icon := range iconset.Icons {
+		if icon == nil {
+			t.Fatalf("want every size rendered")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package icns
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Pool bounds how many icons are resized and encoded at once.
+// A single Pool can be shared by many Encoders to bound the work across all
+// of them. The zero value runs up to GOMAXPROCS jobs at once.
+type Pool struct {
+	sem  chan struct{}
+	once sync.Once
+}
+
+// NewPool initialises a pool that runs at most size jobs at once.
+// Sizes less than one are treated as one.
+func NewPool(size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{sem: make(chan struct{}, size)}
+}
+
+// acquire blocks until a slot is free or ctx is done.
+func (p *Pool) acquire(ctx context.Context) error {
+	p.once.Do(func() {
+		if p.sem == nil {
+			p.sem = make(chan struct{}, runtime.GOMAXPROCS(0))
+		}
+	})
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	<-p.sem
+}
@@ -0,0 +1,130 @@
+package icns
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gaugeResampler records the most resizes it ran at once.
+type gaugeResampler struct {
+	running, max int32
+}
+
+func (g *gaugeResampler) Resize(img image.Image, width, height uint) image.Image {
+	n := atomic.AddInt32(&g.running, 1)
+	for {
+		max := atomic.LoadInt32(&g.max)
+		if n <= max || atomic.CompareAndSwapInt32(&g.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	atomic.AddInt32(&g.running, -1)
+	return image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+}
+
+func TestSharedPool(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc string
+		run  func(enc *Encoder) error
+	}{
+		{"encode", func(enc *Encoder) error {
+			return enc.Encode(rect(0, 0, 1024, 1024))
+		}},
+		{"images", func(enc *Encoder) error {
+			_, err := enc.ImagesContext(context.Background(), rect(0, 0, 1024, 1024), sizes)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			var (
+				g    = &gaugeResampler{}
+				pool = NewPool(2)
+				work sync.WaitGroup
+			)
+			for ii := 0; ii < 4; ii++ {
+				work.Add(1)
+				go func() {
+					defer work.Done()
+					enc := NewEncoder(io.Discard).WithResampler(g).WithPool(pool)
+					if err := tt.run(enc); err != nil {
+						st.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+			work.Wait()
+			if g.max > 2 {
+				st.Errorf("concurrency: want at most 2, got %d", g.max)
+			}
+		})
+	}
+}
+
+func TestZeroPool(t *testing.T) {
+	t.Parallel()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewEncoder(io.Discard).WithPool(&Pool{}).Encode(rect(0, 0, 64, 64))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("encoding with a zero Pool did not finish")
+	}
+}
+
+// cancelResampler cancels the encode on its first resize.
+type cancelResampler struct {
+	cancel context.CancelFunc
+	calls  int32
+}
+
+func (c *cancelResampler) Resize(img image.Image, width, height uint) image.Image {
+	atomic.AddInt32(&c.calls, 1)
+	c.cancel()
+	return image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+}
+
+func TestEncodeContext(t *testing.T) {
+	t.Parallel()
+	t.Run("already cancelled", func(st *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := NewEncoder(io.Discard).EncodeContext(ctx, rect(0, 0, 64, 64))
+		if !errors.Is(err, context.Canceled) {
+			st.Fatalf("want context.Canceled, got %v", err)
+		}
+	})
+	t.Run("cancelled while encoding", func(st *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &cancelResampler{cancel: cancel}
+		_, err := NewEncoder(nil).
+			WithResampler(r).
+			WithConcurrency(1).
+			IconSetContext(ctx, rect(0, 0, 1024, 1024))
+		if !errors.Is(err, context.Canceled) {
+			st.Fatalf("want context.Canceled, got %v", err)
+		}
+		if r.calls != 1 {
+			st.Errorf("want no resizes after cancellation, got %d", r.calls)
+		}
+	})
+	t.Run("NewIconSetContext", func(st *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := NewIconSetContext(ctx, rect(0, 0, 64, 64), NearestNeighbor); !errors.Is(err, context.Canceled) {
+			st.Fatalf("want context.Canceled, got %v", err)
+		}
+	})
+}
@@ -8,40 +8,79 @@ import (
 	"io"
 	"io/ioutil"
 	"sort"
+	"time"
 )
 
 var jpeg2000header = []byte{0x00, 0x00, 0x00, 0x0c, 0x6a, 0x50, 0x20, 0x20}
 
+// Decoder decodes icns files.
+type Decoder struct {
+	Rd io.Reader
+	// Observer, if set, receives progress events.
+	Observer Observer
+}
+
+// NewDecoder initialises a decoder.
+func NewDecoder(rd io.Reader) *Decoder {
+	return &Decoder{Rd: rd}
+}
+
+// WithObserver reports progress events to o.
+func (dec *Decoder) WithObserver(o Observer) *Decoder {
+	dec.Observer = o
+	return dec
+}
+
 // Decode finds the largest icon listed in the icns file and returns it,
 // ignoring all other sizes. The format returned will be whatever the icon data
 // is, typically jpeg or png.
 func Decode(r io.Reader) (image.Image, error) {
-	icons, err := decode(r)
+	return NewDecoder(r).Decode()
+}
+
+// DecodeAll extracts all icon resolutions present in the icns data.
+func DecodeAll(r io.Reader) (images []image.Image, err error) {
+	return NewDecoder(r).DecodeAll()
+}
+
+// Decode finds the largest icon listed in the icns file and returns it.
+func (dec *Decoder) Decode() (image.Image, error) {
+	icons, err := decode(dec.Rd)
 	if err != nil {
 		return nil, err
 	}
 	sort.Slice(icons, func(ii, jj int) bool {
 		return icons[ii].OsType.Size > icons[jj].OsType.Size
 	})
-	img, _, err := image.Decode(icons[0].r)
-	if err != nil {
-		return nil, fmt.Errorf("decoding largest image: %w", err)
+	var (
+		start    = time.Now()
+		progress = &notifier{observer: dec.Observer}
+		stats    Stats
+	)
+	// Legacy icons that cannot be decoded are skipped, so try the next
+	// largest until one decodes.
+	for ii := range icons {
+		decoded, err := decodeIcons(icons[ii:ii+1], progress, &stats)
+		if err != nil {
+			return nil, fmt.Errorf("decoding largest image: %w", err)
+		}
+		if len(decoded) > 0 {
+			stats.finishDecode(start)
+			progress.notify(Event{Kind: Finished, Stats: &stats})
+			return decoded[0].Image, nil
+		}
 	}
-	return img, nil
+	return nil, fmt.Errorf("no icons found")
 }
 
 // DecodeAll extracts all icon resolutions present in the icns data.
-func DecodeAll(r io.Reader) (images []image.Image, err error) {
-	icons, err := decode(r)
+func (dec *Decoder) DecodeAll() (images []image.Image, err error) {
+	set, err := dec.DecodeIconSet()
 	if err != nil {
 		return nil, err
 	}
-	for _, icon := range icons {
-		img, _, err := image.Decode(icon.r)
-		if err != nil {
-			return nil, fmt.Errorf("decoding %q icon: %w", icon.OsType, err)
-		}
-		images = append(images, img)
+	for _, icon := range set.Icons {
+		images = append(images, icon.Image)
 	}
 	sort.Slice(images, func(ii, jj int) bool {
 		var (
@@ -53,6 +92,58 @@ func DecodeAll(r io.Reader) (images []image.Image, err error) {
 	return images, nil
 }
 
+// DecodeIconSet extracts every icon in the icns data along with its type.
+// Icons stored as png keep their original data when written out again.
+// The set's Stats report the decoded icons and the time taken.
+func (dec *Decoder) DecodeIconSet() (*IconSet, error) {
+	var (
+		start    = time.Now()
+		progress = &notifier{observer: dec.Observer}
+		set      = &IconSet{}
+	)
+	icons, err := decode(dec.Rd)
+	if err != nil {
+		return nil, err
+	}
+	set.Icons, err = decodeIcons(icons, progress, &set.Stats)
+	if err != nil {
+		return nil, err
+	}
+	if len(set.Icons) == 0 {
+		return nil, fmt.Errorf("no icons found")
+	}
+	set.Stats.finishDecode(start)
+	progress.notify(Event{Kind: Finished, Stats: &set.Stats})
+	return set, nil
+}
+
+// decodeIcons decodes each icon, recording them in stats.
+func decodeIcons(icons []iconReader, progress *notifier, stats *Stats) (decoded []*Icon, err error) {
+	for _, icon := range icons {
+		progress.notify(Event{Kind: EntryStarted, Type: icon.OsType})
+		var (
+			data = icon.r.Bytes()
+			size = len(data)
+		)
+		img, format, err := image.Decode(icon.r)
+		if err != nil && isLegacy(icon.OsType) {
+			// Legacy types may hold raw rgb data rather than png.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %q icon: %w", icon.OsType.ID, err)
+		}
+		entry := &Icon{Type: icon.OsType, Image: img}
+		if format == "png" {
+			entry.data = data
+		}
+		decoded = append(decoded, entry)
+		stats.add(icon.OsType, size)
+		progress.notify(Event{Kind: EntryFinished, Type: icon.OsType, Bytes: size})
+	}
+	return decoded, nil
+}
+
 func decode(r io.Reader) (icons []iconReader, err error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -103,7 +194,7 @@ func decode(r io.Reader) (icons []iconReader, err error) {
 
 type iconReader struct {
 	OsType
-	r io.Reader
+	r *bytes.Buffer
 }
 
 func isOsType(ID string) bool {
@@ -0,0 +1,154 @@
+package icns
+
+import (
+	"image"
+	"math"
+)
+
+// Sharpen is an unsharp mask applied to each icon after it is downscaled.
+type Sharpen struct {
+	// Radius of the blur (in px at the icon size).
+	Radius float64
+	// Amount is the strength of the mask at a 16x downscale.
+	// Strength scales with the log2 of the downscale ratio, so a 4x downscale
+	// gets half the amount and a 256x downscale gets double.
+	Amount float64
+	// Threshold is the smallest difference (0 to 255) between a pixel and its
+	// blurred value that gets sharpened, which keeps smooth areas free of noise.
+	Threshold uint8
+}
+
+// strength returns the amount to use for a downscale by ratio.
+func (s Sharpen) strength(ratio float64) float64 {
+	if ratio <= 1 {
+		return 0
+	}
+	return s.Amount * math.Log2(ratio) / 4
+}
+
+// apply sharpens img, which was downscaled by ratio.
+// Alpha is left untouched, and 16 bit images stay 16 bit.
+func (s Sharpen) apply(img image.Image, ratio float64) image.Image {
+	amount := s.strength(ratio)
+	if amount <= 0 || s.Radius <= 0 {
+		return img
+	}
+	var (
+		sixteen = is16Bit(img)
+		src     = toDepth(img, sixteen)
+		w, h    = src.Bounds().Dx(), src.Bounds().Dy()
+		// Samples are kept on a 0 to 255 scale whatever the depth, so that the
+		// threshold means the same thing for both.
+		pix     = make([]float32, w*h*4)
+		blurred = make([]float32, w*h*4)
+	)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := pix[(y*w+x)*4:]
+			switch src := src.(type) {
+			case *image.NRGBA64:
+				c := src.NRGBA64At(x, y)
+				p[0], p[1], p[2], p[3] = float32(c.R)/0x101, float32(c.G)/0x101, float32(c.B)/0x101, float32(c.A)/0x101
+			case *image.NRGBA:
+				c := src.NRGBAAt(x, y)
+				p[0], p[1], p[2], p[3] = float32(c.R), float32(c.G), float32(c.B), float32(c.A)
+			}
+			// Blur with premultiplied alpha so transparent pixels don't bleed
+			// into the edges of the artwork.
+			var (
+				d = blurred[(y*w+x)*4:]
+				a = p[3] / 0xff
+			)
+			d[0], d[1], d[2], d[3] = p[0]*a, p[1]*a, p[2]*a, a
+		}
+	}
+	kernel := gaussian(s.Radius)
+	blurred = blur(blurred, w, h, 4, kernel, true)
+	blurred = blur(blurred, w, h, 4, kernel, false)
+	for ii := 0; ii < len(pix); ii += 4 {
+		var (
+			p = pix[ii : ii+4]
+			b = blurred[ii : ii+4]
+		)
+		if p[3] == 0 || b[3] == 0 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			diff := p[c] - b[c]/b[3]
+			if math.Abs(float64(diff)) < float64(s.Threshold) {
+				continue
+			}
+			p[c] = clamp(p[c]+diff*float32(amount), 0xff)
+		}
+	}
+	if sixteen {
+		dst := image.NewNRGBA64(image.Rect(0, 0, w, h))
+		for ii, v := range pix {
+			dst.Pix[ii*2] = uint8(uint16(v*0x101+0.5) >> 8)
+			dst.Pix[ii*2+1] = uint8(uint16(v*0x101 + 0.5))
+		}
+		return dst
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for ii, v := range pix {
+		dst.Pix[ii] = uint8(v + 0.5)
+	}
+	return dst
+}
+
+// gaussian returns a normalised, symmetric kernel for the given radius, where
+// the radius is used as the standard deviation.
+func gaussian(radius float64) []float32 {
+	var (
+		half   = int(math.Ceil(radius * 3))
+		kernel = make([]float32, half*2+1)
+		sum    float32
+	)
+	for ii := range kernel {
+		x := float64(ii - half)
+		kernel[ii] = float32(math.Exp(-x * x / (2 * radius * radius)))
+		sum += kernel[ii]
+	}
+	for ii := range kernel {
+		kernel[ii] /= sum
+	}
+	return kernel
+}
+
+// blur convolves pix (w by h pixels of up to 4 channels) with kernel along one
+// axis, clamping samples at the edges.
+func blur(pix []float32, w, h, channels int, kernel []float32, horizontal bool) []float32 {
+	var (
+		out  = make([]float32, len(pix))
+		half = len(kernel) / 2
+	)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var acc [4]float32
+			for ii, k := range kernel {
+				sx, sy := x, y
+				if horizontal {
+					sx = clampInt(x+ii-half, 0, w-1)
+				} else {
+					sy = clampInt(y+ii-half, 0, h-1)
+				}
+				s := pix[(sy*w+sx)*channels:]
+				for c := 0; c < channels; c++ {
+					acc[c] += s[c] * k
+				}
+			}
+			copy(out[(y*w+x)*channels:], acc[:channels])
+		}
+	}
+	return out
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSharpenStrength(t *testing.T) {
+	t.Parallel()
+	s := Sharpen{Amount: 1}
+	tests := []struct {
+		ratio float64
+		want  float64
+	}{
+		{0.5, 0},
+		{1, 0},
+		{4, 0.5},
+		{16, 1},
+		{64, 1.5},
+	}
+	for _, tt := range tests {
+		if got := s.strength(tt.ratio); got != tt.want {
+			t.Errorf("ratio %v: want=%v, got=%v", tt.ratio, tt.want, got)
+		}
+	}
+}
+
+func TestSharpen(t *testing.T) {
+	t.Parallel()
+	// A soft vertical edge ramping from dark to light.
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 4))
+	ramp := []uint8{64, 64, 64, 64, 64, 64, 96, 128, 160, 192, 192, 192, 192, 192, 192, 192}
+	for y := 0; y < 4; y++ {
+		for x, v := range ramp {
+			src.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+	at := func(img image.Image, x int) uint8 {
+		return color.NRGBAModel.Convert(img.At(x, 1)).(color.NRGBA).R
+	}
+	t.Run("increases edge contrast", func(st *testing.T) {
+		got := Sharpen{Radius: 1, Amount: 1}.apply(src, 16)
+		if at(got, 5) >= at(src, 5) {
+			st.Errorf("dark side of edge: want < %d, got %d", at(src, 5), at(got, 5))
+		}
+		if at(got, 10) <= at(src, 10) {
+			st.Errorf("light side of edge: want > %d, got %d", at(src, 10), at(got, 10))
+		}
+		if at(got, 0) != at(src, 0) || at(got, 15) != at(src, 15) {
+			st.Errorf("flat areas changed: %d, %d", at(got, 0), at(got, 15))
+		}
+	})
+	t.Run("threshold protects small differences", func(st *testing.T) {
+		got := Sharpen{Radius: 1, Amount: 1, Threshold: 0xff}.apply(src, 16)
+		for x := range ramp {
+			if at(got, x) != at(src, x) {
+				st.Errorf("x=%d: want=%d, got=%d", x, at(src, x), at(got, x))
+			}
+		}
+	})
+	t.Run("upscales are untouched", func(st *testing.T) {
+		if got := (Sharpen{Radius: 1, Amount: 1}).apply(src, 0.5); got != image.Image(src) {
+			st.Errorf("want source image returned")
+		}
+	})
+}
@@ -0,0 +1,13 @@
+package icns
+
+import (
+	"image"
+)
+
+// SourceProvider renders the source at any size, such as a vector image, so
+// that each icon is drawn at its own size rather than resized from the
+// biggest.
+type SourceProvider interface {
+	// Image renders the source as a size by size image.
+	Image(size uint) (image.Image, error)
+}
@@ -0,0 +1,53 @@
+package icns
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"testing"
+)
+
+// sourceFunc adapts a function to SourceProvider.
+type sourceFunc func(size uint) (image.Image, error)
+
+func (f sourceFunc) Image(size uint) (image.Image, error) {
+	return f(size)
+}
+
+func TestIconSetSource(t *testing.T) {
+	t.Parallel()
+	var (
+		mu       sync.Mutex
+		rendered = map[uint]bool{}
+	)
+	iconset, err := NewEncoder(nil).
+		WithStyle(Style{}).
+		IconSetSource(sourceFunc(func(size uint) (image.Image, error) {
+			mu.Lock()
+			rendered[size] = true
+			mu.Unlock()
+			// Render at double the size asked for, which must be resized.
+			return opaqueImage(int(size) * 2), nil
+		}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, icon := range iconset.Icons {
+		if icon == nil {
+			t.Fatalf("want every size rendered")
+		}
+		if inner := (Style{}).inner(icon.Type.Size); !rendered[inner] {
+			t.Errorf("%s: want %dpx artwork rendered", icon.Type.ID, inner)
+		}
+		if got := icon.Image.Bounds().Dx(); got != int(icon.Type.Size) {
+			t.Errorf("%s: want %dpx, got %dpx", icon.Type.ID, icon.Type.Size, got)
+		}
+	}
+	failure := errors.New("boom")
+	_, err = NewEncoder(nil).IconSetSource(sourceFunc(func(uint) (image.Image, error) {
+		return nil, failure
+	}))
+	if !errors.Is(err, failure) {
+		t.Errorf("want render error, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Style composites square artwork into the macOS icon template: the artwork
+// is scaled into a continuous-corner rounded rectangle ("squircle") 824px
+// wide on the 1024px grid, over an optional background and drop shadow.
+// It is applied at every icon size so that the edges stay crisp.
+type Style struct {
+	// Background, if set, fills the shape behind the artwork, which suits
+	// transparent sources and flat artwork that doesn't cover the square.
+	Background color.Color
+	// Shadow toggles the standard drop shadow beneath the shape.
+	Shadow bool
+}
+
+// Template dimensions, as fractions of the icon side.
+const (
+	stylePadding      = 100.0 / 1024
+	styleShadowOffset = 10.0 / 1024
+	styleShadowBlur   = 10.0 / 1024
+	styleShadowAlpha  = 0.3
+	// squircleExponent gives the superellipse that approximates the template's
+	// continuous corners.
+	squircleExponent = 5
+)
+
+// padding returns the space (in px) between the shape and each edge of an
+// icon of the given size, rounded to a whole pixel. The same padding is used
+// on every side, so the shape stays centred on whole pixels.
+func (Style) padding(size uint) uint {
+	return uint(math.Round(float64(size) * stylePadding))
+}
+
+// inner returns the side (in px) that the artwork is resized to for an icon
+// of the given size.
+func (s Style) inner(size uint) uint {
+	return size - 2*s.padding(size)
+}
+
+// apply composites the artwork, already resized to s.inner(size), onto an
+// icon of the given size.
+func (s Style) apply(artwork image.Image, size uint) image.Image {
+	var (
+		side   = int(size)
+		pad    = int(s.padding(size))
+		bounds = image.Rect(0, 0, side, side)
+		shape  = image.Rect(pad, pad, side-pad, side-pad)
+		mask   = squircle(bounds, shape)
+		dst    = image.NewRGBA64(bounds)
+	)
+	if s.Shadow {
+		draw.DrawMask(dst, bounds, image.Black, image.Point{}, shadow(mask, float64(size)), image.Point{}, draw.Over)
+	}
+	if s.Background != nil {
+		draw.DrawMask(dst, shape, image.NewUniform(s.Background), image.Point{}, mask, shape.Min, draw.Over)
+	}
+	draw.DrawMask(dst, shape, artwork, artwork.Bounds().Min, mask, shape.Min, draw.Over)
+	return dst
+}
+
+// squircle returns the antialiased coverage of the superellipse inscribed in
+// shape, over bounds.
+func squircle(bounds, shape image.Rectangle) *image.Alpha16 {
+	var (
+		mask   = image.NewAlpha16(bounds)
+		radius = float64(shape.Dx()) / 2
+		cx     = float64(shape.Min.X) + radius
+		cy     = float64(shape.Min.Y) + radius
+	)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		v := math.Abs(float64(y)+0.5-cy) / radius
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			u := math.Abs(float64(x)+0.5-cx) / radius
+			f := math.Pow(u, squircleExponent) + math.Pow(v, squircleExponent)
+			// The distance to the edge is approximated by the change in
+			// radius, which is exact along the axes and close elsewhere.
+			coverage := 0.5 - radius*(math.Pow(f, 1.0/squircleExponent)-1)
+			mask.SetAlpha16(x, y, color.Alpha16{A: uint16(clamp(float32(coverage), 1) * 0xffff)})
+		}
+	}
+	return mask
+}
+
+// shadow returns the shape mask offset downwards, blurred and faded into the
+// template's drop shadow for an icon of the given size.
+func shadow(mask *image.Alpha16, size float64) *image.Alpha16 {
+	var (
+		b      = mask.Bounds()
+		w, h   = b.Dx(), b.Dy()
+		offset = int(math.Round(size * styleShadowOffset))
+		pix    = make([]float32, w*h)
+	)
+	for y := 0; y < h; y++ {
+		if y-offset < 0 {
+			continue
+		}
+		for x := 0; x < w; x++ {
+			pix[y*w+x] = float32(mask.Alpha16At(b.Min.X+x, b.Min.Y+y-offset).A) / 0xffff
+		}
+	}
+	kernel := gaussian(size * styleShadowBlur)
+	pix = blur(pix, w, h, 1, kernel, true)
+	pix = blur(pix, w, h, 1, kernel, false)
+	out := image.NewAlpha16(b)
+	for ii, v := range pix {
+		a := uint16(clamp(v*styleShadowAlpha, 1) * 0xffff)
+		out.Pix[ii*2], out.Pix[ii*2+1] = uint8(a>>8), uint8(a)
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStyle(t *testing.T) {
+	t.Parallel()
+	opaque := image.NewUniform(color.NRGBA{R: 0xff, A: 0xff})
+	alpha := func(img image.Image, x, y int) uint32 {
+		_, _, _, a := img.At(x, y).RGBA()
+		return a
+	}
+	t.Run("geometry", func(st *testing.T) {
+		s := Style{}
+		for _, tt := range []struct{ size, inner uint }{
+			{16, 12}, {32, 26}, {64, 52}, {1024, 824},
+		} {
+			if got := s.inner(tt.size); got != tt.inner {
+				st.Errorf("%dpx: want inner %d, got %d", tt.size, tt.inner, got)
+			}
+		}
+	})
+	t.Run("mask", func(st *testing.T) {
+		got := Style{}.apply(image.NewNRGBA(image.Rect(0, 0, 824, 824)), 1024)
+		if got.Bounds() != image.Rect(0, 0, 1024, 1024) {
+			st.Fatalf("want 1024px icon, got %v", got.Bounds())
+		}
+		art := Style{}.apply(opaqueImage(824), 1024)
+		for _, tt := range []struct {
+			desc string
+			x, y int
+			test func(a uint32) bool
+		}{
+			{"centre", 512, 512, func(a uint32) bool { return a == 0xffff }},
+			{"edge midpoint", 512, 100, func(a uint32) bool { return a > 0x7fff }},
+			{"padding", 512, 50, func(a uint32) bool { return a == 0 }},
+			{"rounded corner", 105, 105, func(a uint32) bool { return a == 0 }},
+			{"inside corner", 160, 160, func(a uint32) bool { return a == 0xffff }},
+		} {
+			if a := alpha(art, tt.x, tt.y); !tt.test(a) {
+				st.Errorf("%s at (%d,%d): unexpected alpha %d", tt.desc, tt.x, tt.y, a)
+			}
+		}
+	})
+	t.Run("background", func(st *testing.T) {
+		got := Style{Background: opaque}.apply(image.NewNRGBA(image.Rect(0, 0, 52, 52)), 64)
+		if c := color.NRGBAModel.Convert(got.At(32, 32)).(color.NRGBA); c != (color.NRGBA{R: 0xff, A: 0xff}) {
+			st.Errorf("want background fill, got %v", c)
+		}
+		if a := alpha(got, 1, 1); a != 0 {
+			st.Errorf("want transparent padding, got alpha %d", a)
+		}
+	})
+	t.Run("shadow", func(st *testing.T) {
+		plain := Style{}.apply(opaqueImage(824), 1024)
+		shaded := Style{Shadow: true}.apply(opaqueImage(824), 1024)
+		if a := alpha(plain, 512, 930); a != 0 {
+			st.Errorf("want no shadow, got alpha %d", a)
+		}
+		r, g, b, a := shaded.At(512, 930).RGBA()
+		if a == 0 || r != 0 || g != 0 || b != 0 {
+			st.Errorf("want black shadow below the shape, got %v", shaded.At(512, 930))
+		}
+		if above := alpha(shaded, 512, 94); above >= a {
+			st.Errorf("want shadow offset downwards, got alpha %d above and %d below", above, a)
+		}
+	})
+	t.Run("encoder", func(st *testing.T) {
+		iconset, err := NewEncoder(nil).
+			WithStyle(Style{Shadow: true}).
+			IconSet(opaqueImage(1024))
+		if err != nil {
+			st.Fatalf("unexpected error: %v", err)
+		}
+		for _, icon := range iconset.Icons {
+			if icon == nil {
+				continue
+			}
+			size := int(icon.Type.Size)
+			if icon.Image.Bounds().Dx() != size {
+				st.Errorf("%s: want %dpx, got %v", icon.Type.ID, size, icon.Image.Bounds())
+			}
+			if icon.Upscaled {
+				st.Errorf("%s: unexpectedly upscaled", icon.Type.ID)
+			}
+			if a := alpha(icon.Image, 0, 0); a != 0 {
+				st.Errorf("%s: want transparent corner, got alpha %d", icon.Type.ID, a)
+			}
+		}
+	})
+}
+
+func opaqueImage(side int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, side, side))
+	for ii := range img.Pix {
+		img.Pix[ii] = 0xff
+	}
+	return img
+}
@@ -0,0 +1,86 @@
+package svg
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+const logo = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 200 100">
+	<style>.mark { fill: #00ff00; }</style>
+	<defs>
+		<linearGradient id="fade" x1="0" y1="0" x2="1" y2="0">
+			<stop offset="0" stop-color="#ff0000"/>
+			<stop offset="1" stop-color="#0000ff"/>
+		</linearGradient>
+	</defs>
+	<rect width="100" height="100" fill="url(#fade)"/>
+	<g transform="translate(100 0)">
+		<circle class="mark" cx="50" cy="50" r="40"/>
+	</g>
+</svg>`
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+	src, err := Parse(strings.NewReader(logo))
+	if err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+	nrgba := func(c color.Color) color.NRGBA {
+		return color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+	for _, size := range []uint{16, 64, 512} {
+		img, err := src.Image(size)
+		if err != nil {
+			t.Fatalf("%dpx: rendering: %v", size, err)
+		}
+		s := int(size)
+		if img.Bounds().Dx() != s || img.Bounds().Dy() != s {
+			t.Fatalf("%dpx: want square image, got %v", size, img.Bounds())
+		}
+		// The 2:1 view box is centred vertically, leaving a quarter of the
+		// height transparent above and below.
+		if c := nrgba(img.At(s/2, s/8)); c.A != 0 {
+			t.Errorf("%dpx: want transparent padding, got %v", size, c)
+		}
+		if c := nrgba(img.At(s*3/4, s/2)); c != (color.NRGBA{G: 0xff, A: 0xff}) {
+			t.Errorf("%dpx: want circle styled by class, got %v", size, c)
+		}
+		left, right := nrgba(img.At(1, s/2)), nrgba(img.At(s/2-2, s/2))
+		if left.R <= left.B || right.B <= right.R {
+			t.Errorf("%dpx: want gradient from red to blue, got %v to %v", size, left, right)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+	for _, data := range []string{"", "<svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`} {
+		if _, err := Parse(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: want error", data)
+		}
+	}
+}
+
+func TestStrokeScales(t *testing.T) {
+	t.Parallel()
+	src, err := Parse(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+		<path d="M0 5 L10 5" stroke="#000" stroke-width="4"/>
+	</svg>`))
+	if err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+	img, err := src.Image(100)
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	// A 4 unit stroke is 40px thick at 10x.
+	for _, y := range []int{32, 50, 67} {
+		if _, _, _, a := img.At(50, y).RGBA(); a != 0xffff {
+			t.Errorf("want stroke at y=%d, got alpha %d", y, a)
+		}
+	}
+	if _, _, _, a := img.At(50, 75).RGBA(); a != 0 {
+		t.Errorf("want no stroke at y=75, got alpha %d", a)
+	}
+}
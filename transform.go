@@ -0,0 +1,219 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// HueShift is a Filter that rotates the hue of every pixel.
+type HueShift struct {
+	// Degrees to rotate the hue by.
+	Degrees float64
+}
+
+// Apply returns a copy of img with the hue rotated.
+func (h HueShift) Apply(img image.Image) image.Image {
+	shift := h.Degrees / 360
+	return mapColors(img, func(r, g, b float64) (float64, float64, float64) {
+		hue, sat, light := toHSL(r, g, b)
+		hue = math.Mod(hue+shift, 1)
+		if hue < 0 {
+			hue++
+		}
+		return fromHSL(hue, sat, light)
+	})
+}
+
+// Tint is a Filter that recolours every pixel with the hue and saturation of
+// a colour, keeping its lightness.
+type Tint struct {
+	Color color.Color
+	// Amount of the tint, from 0 to 1. Defaults to 1 if zero.
+	Amount float64
+}
+
+// Apply returns a tinted copy of img.
+func (t Tint) Apply(img image.Image) image.Image {
+	if t.Color == nil {
+		return cloneImage(img)
+	}
+	var (
+		c         = color.NRGBAModel.Convert(t.Color).(color.NRGBA)
+		hue, s, _ = toHSL(float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff)
+		amount    = fullIfZero(t.Amount)
+	)
+	return mapColors(img, func(r, g, b float64) (float64, float64, float64) {
+		_, _, light := toHSL(r, g, b)
+		tr, tg, tb := fromHSL(hue, s, light)
+		return mix(r, tr, amount), mix(g, tg, amount), mix(b, tb, amount)
+	})
+}
+
+// Grayscale is a Filter that desaturates every pixel, as for a disabled
+// icon.
+type Grayscale struct {
+	// Amount of desaturation, from 0 to 1. Defaults to 1 if zero.
+	Amount float64
+}
+
+// Apply returns a desaturated copy of img.
+func (gs Grayscale) Apply(img image.Image) image.Image {
+	amount := fullIfZero(gs.Amount)
+	return mapColors(img, func(r, g, b float64) (float64, float64, float64) {
+		// Rec. 709 luma.
+		y := 0.2126*r + 0.7152*g + 0.0722*b
+		return mix(r, y, amount), mix(g, y, amount), mix(b, y, amount)
+	})
+}
+
+// Template is a Filter that turns img into a single colour silhouette,
+// keeping only the alpha of each pixel, as for macOS template images.
+type Template struct {
+	// Color of the silhouette. Defaults to black if nil.
+	Color color.Color
+}
+
+// Apply returns img as a silhouette.
+func (t Template) Apply(img image.Image) image.Image {
+	c := color.NRGBA{A: 0xff}
+	if t.Color != nil {
+		c = color.NRGBAModel.Convert(t.Color).(color.NRGBA)
+	}
+	var (
+		dst        = cloneImage(img)
+		pix, depth = samples(dst)
+		r, g       = float64(c.R) / 0xff, float64(c.G) / 0xff
+		b, a       = float64(c.B) / 0xff, float64(c.A) / 0xff
+	)
+	for ii := 0; ii < len(pix); ii += 4 * depth {
+		p := pix[ii : ii+4*depth]
+		alpha := channel(p[3*depth:], depth) * a
+		for jj, v := range []float64{r, g, b, alpha} {
+			setChannel(p[jj*depth:], depth, v)
+		}
+	}
+	return dst
+}
+
+// mapColors returns a copy of img with f applied to the unpremultiplied
+// colour of every pixel, on a 0 to 1 scale. Alpha is left untouched.
+func mapColors(img image.Image, f func(r, g, b float64) (float64, float64, float64)) image.Image {
+	var (
+		dst        = cloneImage(img)
+		pix, depth = samples(dst)
+	)
+	for ii := 0; ii < len(pix); ii += 4 * depth {
+		p := pix[ii : ii+4*depth]
+		if channel(p[3*depth:], depth) == 0 {
+			continue
+		}
+		r, g, b := f(channel(p, depth), channel(p[depth:], depth), channel(p[2*depth:], depth))
+		setChannel(p, depth, r)
+		setChannel(p[depth:], depth, g)
+		setChannel(p[2*depth:], depth, b)
+	}
+	return dst
+}
+
+// samples returns the pixels of an image made by cloneImage, and the bytes
+// per sample.
+func samples(img image.Image) ([]uint8, int) {
+	if img, ok := img.(*image.NRGBA64); ok {
+		return img.Pix, 2
+	}
+	return img.(*image.NRGBA).Pix, 1
+}
+
+// channel reads a big endian sample of depth bytes as 0 to 1.
+func channel(p []uint8, depth int) float64 {
+	if depth == 2 {
+		return float64(uint16(p[0])<<8|uint16(p[1])) / 0xffff
+	}
+	return float64(p[0]) / 0xff
+}
+
+// setChannel writes v, clamped to 0 to 1, as a big endian sample of depth
+// bytes.
+func setChannel(p []uint8, depth int, v float64) {
+	v = math.Max(0, math.Min(1, v))
+	if depth == 2 {
+		s := uint16(v*0xffff + 0.5)
+		p[0], p[1] = uint8(s>>8), uint8(s)
+		return
+	}
+	p[0] = uint8(v*0xff + 0.5)
+}
+
+// fullIfZero returns amount clamped to 0 to 1, defaulting to 1 if zero.
+func fullIfZero(amount float64) float64 {
+	if amount == 0 {
+		return 1
+	}
+	return math.Max(0, math.Min(1, amount))
+}
+
+func mix(from, to, amount float64) float64 {
+	return from + (to-from)*amount
+}
+
+// toHSL converts rgb to hue, saturation and lightness, all from 0 to 1.
+func toHSL(r, g, b float64) (h, s, l float64) {
+	var (
+		max = math.Max(r, math.Max(g, b))
+		min = math.Min(r, math.Min(g, b))
+	)
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h / 6, s, l
+}
+
+// fromHSL converts hue, saturation and lightness, all from 0 to 1, to rgb.
+func fromHSL(h, s, l float64) (r, g, b float64) {
+	if s == 0 {
+		return l, l, l
+	}
+	q := l * (1 + s)
+	if l >= 0.5 {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	return hueToRGB(p, q, h+1.0/3), hueToRGB(p, q, h), hueToRGB(p, q, h-1.0/3)
+}
+
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t++
+	}
+	if t > 1 {
+		t--
+	}
+	switch {
+	case t < 1.0/6:
+		return p + (q-p)*6*t
+	case t < 1.0/2:
+		return q
+	case t < 2.0/3:
+		return p + (q-p)*(2.0/3-t)*6
+	}
+	return p
+}
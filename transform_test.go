@@ -0,0 +1,67 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestColorTransforms(t *testing.T) {
+	t.Parallel()
+	source := func(c color.NRGBA) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		img.SetNRGBA(0, 0, c)
+		return img
+	}
+	var (
+		red  = color.NRGBA{R: 0xff, A: 0xff}
+		half = color.NRGBA{R: 0xff, A: 0x80}
+	)
+	tests := []struct {
+		desc   string
+		filter Filter
+		src    color.NRGBA
+		want   color.NRGBA
+	}{
+		{"hue shift", HueShift{Degrees: 120}, red, color.NRGBA{G: 0xff, A: 0xff}},
+		{"negative hue shift", HueShift{Degrees: -120}, red, color.NRGBA{B: 0xff, A: 0xff}},
+		{"hue shift keeps alpha", HueShift{Degrees: 240}, half, color.NRGBA{B: 0xff, A: 0x80}},
+		{"tint", Tint{Color: color.NRGBA{B: 0xff, A: 0xff}}, red, color.NRGBA{B: 0xff, A: 0xff}},
+		{"tint keeps lightness", Tint{Color: color.NRGBA{B: 0xff, A: 0xff}}, color.NRGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xff}, color.NRGBA{B: 0x80, A: 0xff}},
+		{"partial tint", Tint{Color: color.NRGBA{B: 0xff, A: 0xff}, Amount: 0.5}, red, color.NRGBA{R: 0x80, B: 0x80, A: 0xff}},
+		{"grayscale", Grayscale{}, red, color.NRGBA{R: 0x36, G: 0x36, B: 0x36, A: 0xff}},
+		{"template", Template{}, half, color.NRGBA{A: 0x80}},
+		{"coloured template", Template{Color: color.NRGBA{G: 0xff, A: 0x80}}, red, color.NRGBA{G: 0xff, A: 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			src := source(tt.src)
+			got := color.NRGBAModel.Convert(tt.filter.Apply(src).At(0, 0)).(color.NRGBA)
+			if got != tt.want {
+				st.Errorf("want=%v, got=%v", tt.want, got)
+			}
+			if c := src.(*image.NRGBA).NRGBAAt(0, 0); c != tt.src {
+				st.Errorf("source modified: %v", c)
+			}
+		})
+	}
+	t.Run("chained with 16 bit source", func(st *testing.T) {
+		img := image.NewNRGBA64(image.Rect(0, 0, 64, 64))
+		for ii := range img.Pix {
+			img.Pix[ii] = 0xff
+		}
+		img.SetNRGBA64(0, 0, color.NRGBA64{R: 0x1234, A: 0xffff})
+		iconset, err := NewEncoder(nil).
+			WithBitDepth(BitDepthPreserve).
+			WithFilters(HueShift{Degrees: 120}, Grayscale{Amount: 0.5}).
+			IconSet(img)
+		if err != nil {
+			st.Fatalf("unexpected error: %v", err)
+		}
+		for _, icon := range iconset.Icons {
+			if icon != nil && !is16Bit(icon.Image) {
+				st.Errorf("%s: want 16 bit icon, got %T", icon.Type.ID, icon.Image)
+			}
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package icns
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// SafeArea is the margin (in percent) of the macOS icon grid, which keeps
+// artwork within the central 824px of a 1024px icon.
+const SafeArea = 100.0 / 1024 * 100
+
+// Trim removes the transparent border around the artwork and recentres it
+// on a square canvas before resizing.
+type Trim struct {
+	// Margin is the transparent space left on each side of the artwork, as a
+	// percentage (0 to 50) of the canvas side. See SafeArea.
+	Margin float64
+	// Threshold is the largest alpha (0 to 255) treated as transparent, which
+	// stops faint noise from counting as artwork.
+	Threshold uint8
+}
+
+// apply returns the artwork of img centred on a square canvas with the
+// margin around it. Fully transparent images are returned as is.
+func (t Trim) apply(img image.Image) (image.Image, error) {
+	if t.Margin < 0 || t.Margin >= 50 {
+		return nil, fmt.Errorf("trim margin must be from 0 to 50 percent, got %v", t.Margin)
+	}
+	box := t.bounds(img)
+	if box.Empty() {
+		return img, nil
+	}
+	var (
+		w, h = box.Dx(), box.Dy()
+		side = w
+	)
+	if h > side {
+		side = h
+	}
+	side = int(math.Ceil(float64(side) / (1 - 2*t.Margin/100)))
+	var (
+		rect   = image.Rect(0, 0, side, side)
+		offset = image.Pt((side-w)/2, (side-h)/2)
+		dst    draw.Image
+	)
+	if is16Bit(img) {
+		dst = image.NewNRGBA64(rect)
+	} else {
+		dst = image.NewNRGBA(rect)
+	}
+	draw.Draw(dst, box.Sub(box.Min).Add(offset), img, box.Min, draw.Src)
+	return dst, nil
+}
+
+// bounds returns the smallest rectangle holding every pixel of img with an
+// alpha above the threshold.
+func (t Trim) bounds(img image.Image) image.Rectangle {
+	var (
+		b         = img.Bounds()
+		box       image.Rectangle
+		threshold = uint32(t.Threshold) * 0x101
+	)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a <= threshold {
+				continue
+			}
+			box = box.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return box
+}
@@ -0,0 +1,81 @@
+package icns
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestTrim(t *testing.T) {
+	t.Parallel()
+	// A 40x60 opaque block, off centre on a wide transparent canvas, with a
+	// faint speck in the corner.
+	src := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(src, image.Rect(20, 10, 60, 70), image.NewUniform(color.NRGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+	src.SetNRGBA(199, 99, color.NRGBA{A: 2})
+	tests := []struct {
+		desc string
+		trim Trim
+
+		want image.Rectangle
+		// artwork is where the block is expected to end up.
+		artwork image.Rectangle
+	}{
+		{
+			"no margin",
+			Trim{Threshold: 2},
+			image.Rect(0, 0, 60, 60),
+			image.Rect(10, 0, 50, 60),
+		},
+		{
+			"percentage margin",
+			Trim{Margin: 25, Threshold: 2},
+			image.Rect(0, 0, 120, 120),
+			image.Rect(40, 30, 80, 90),
+		},
+		{
+			"safe area",
+			Trim{Margin: SafeArea, Threshold: 2},
+			image.Rect(0, 0, 75, 75),
+			image.Rect(17, 7, 57, 67),
+		},
+		{
+			"speck counts as artwork",
+			Trim{},
+			image.Rect(0, 0, 180, 180),
+			image.Rect(0, 45, 40, 105),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			got, err := tt.trim.apply(src)
+			if err != nil {
+				st.Fatalf("unexpected error: %v", err)
+			}
+			if got.Bounds() != tt.want {
+				st.Fatalf("want=%v, got=%v", tt.want, got.Bounds())
+			}
+			b := got.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					inside := image.Pt(x, y).In(tt.artwork)
+					if _, _, _, a := got.At(x, y).RGBA(); inside != (a == 0xffff) {
+						st.Fatalf("at (%d,%d): want artwork=%v, got alpha %d", x, y, inside, a)
+					}
+				}
+			}
+		})
+	}
+	t.Run("invalid margin", func(st *testing.T) {
+		if _, err := NewEncoder(nil).WithTrim(Trim{Margin: 50}).IconSet(src); err == nil {
+			st.Fatalf("want error for 50%% margin")
+		}
+	})
+	t.Run("fully transparent", func(st *testing.T) {
+		empty := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		if got, err := (Trim{}).apply(empty); err != nil || got != image.Image(empty) {
+			st.Fatalf("want image untouched, got %v, %v", got.Bounds(), err)
+		}
+	})
+}
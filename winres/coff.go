@@ -0,0 +1,332 @@
+package winres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Arches lists the architectures a resource object can be written for,
+// named as GOARCH.
+var Arches = []string{"amd64", "386", "arm64"}
+
+// machines maps each architecture to its COFF machine type, and the
+// relocation type of an image relative address.
+var machines = map[string]struct{ machine, reloc uint16 }{
+	"amd64": {0x8664, 3}, // IMAGE_REL_AMD64_ADDR32NB
+	"386":   {0x14c, 7},  // IMAGE_REL_I386_DIR32NB
+	"arm64": {0xaa64, 2}, // IMAGE_REL_ARM64_ADDR32NB
+}
+
+// Language of the written resources, en-US.
+const language = 0x409
+
+// resource is a leaf of the resource tree, identified by name if it has one,
+// otherwise by id.
+type resource struct {
+	typ, id uint16
+	name    string
+	data    []byte
+}
+
+// before reports whether r is listed before o in a directory of their type:
+// named entries come first, ordered by name regardless of case, then id
+// entries in ascending order.
+func (r resource) before(o resource) bool {
+	switch {
+	case r.name != "" && o.name != "":
+		return strings.ToUpper(r.name) < strings.ToUpper(o.name)
+	case r.name != "" || o.name != "":
+		return r.name != ""
+	}
+	return r.id < o.id
+}
+
+// WriteSyso writes g as the application icon of a COFF object for arch,
+// which the Go linker embeds into Windows binaries when named with a
+// _windows_<arch>.syso suffix.
+func (g *IconGroup) WriteSyso(w io.Writer, arch string) error {
+	m, ok := machines[arch]
+	if !ok {
+		return fmt.Errorf("unsupported architecture %q", arch)
+	}
+	if len(g.Images) == 0 {
+		return fmt.Errorf("icon has no images")
+	}
+	var resources []resource
+	for ii, img := range g.Images {
+		resources = append(resources, resource{typ: typeIcon, id: uint16(ii + 1), data: img.Data})
+	}
+	resources = append(resources, resource{typ: typeGroupIcon, id: 1, data: g.groupResource(1)})
+	section, relocs := writeResources(resources)
+	return writeObject(w, m.machine, m.reloc, section, relocs)
+}
+
+// writeResources lays out the resource tree, type then name or id then
+// language, followed by the names and the data. It returns the section and
+// the offsets of the data addresses that need relocating.
+func writeResources(resources []resource) ([]byte, []uint32) {
+	sort.SliceStable(resources, func(ii, jj int) bool {
+		if resources[ii].typ != resources[jj].typ {
+			return resources[ii].typ < resources[jj].typ
+		}
+		return resources[ii].before(resources[jj])
+	})
+	var types []uint16
+	for ii, r := range resources {
+		if ii == 0 || r.typ != resources[ii-1].typ {
+			types = append(types, r.typ)
+		}
+	}
+	const (
+		dirSize   = 16
+		entrySize = 8
+		dataSize  = 16
+		subdir    = 1 << 31
+	)
+	var (
+		typeDirs = dirSize + entrySize*len(types)
+		idDirs   = dirSize*len(types) + entrySize*len(resources)
+		langDirs = (dirSize + entrySize) * len(resources)
+		entries  = typeDirs + idDirs + langDirs
+		strs     = entries + dataSize*len(resources)
+		blobs    = strs
+		size     int
+	)
+	// Names are a length followed by that many utf-16 code units.
+	names := make([][]uint16, len(resources))
+	for ii, r := range resources {
+		if r.name != "" {
+			names[ii] = utf16.Encode([]rune(r.name))
+			blobs += 2 + 2*len(names[ii])
+		}
+	}
+	blobs = align(blobs, 8)
+	size = blobs
+	for _, r := range resources {
+		size = align(size+len(r.data), 8)
+	}
+	var (
+		buf    = make([]byte, size)
+		le     = binary.LittleEndian
+		relocs []uint32
+		// Next free offset of each kind of table.
+		idDir   = typeDirs
+		langDir = typeDirs + idDirs
+		str     = strs
+		data    = blobs
+	)
+	dir := func(at int, named, ids int) int {
+		le.PutUint16(buf[at+12:], uint16(named))
+		le.PutUint16(buf[at+14:], uint16(ids))
+		return at + dirSize
+	}
+	entry := dir(0, 0, len(types))
+	for _, typ := range types {
+		var (
+			ids   []int
+			named int
+		)
+		for ii, r := range resources {
+			if r.typ == typ {
+				ids = append(ids, ii)
+				if r.name != "" {
+					named++
+				}
+			}
+		}
+		le.PutUint32(buf[entry:], uint32(typ))
+		le.PutUint32(buf[entry+4:], uint32(idDir)|subdir)
+		entry += entrySize
+		idEntry := dir(idDir, named, len(ids)-named)
+		idDir = idEntry + entrySize*len(ids)
+		for _, ii := range ids {
+			r := resources[ii]
+			if name := names[ii]; name != nil {
+				le.PutUint32(buf[idEntry:], uint32(str)|subdir)
+				le.PutUint16(buf[str:], uint16(len(name)))
+				for jj, c := range name {
+					le.PutUint16(buf[str+2+2*jj:], c)
+				}
+				str += 2 + 2*len(name)
+			} else {
+				le.PutUint32(buf[idEntry:], uint32(r.id))
+			}
+			le.PutUint32(buf[idEntry+4:], uint32(langDir)|subdir)
+			idEntry += entrySize
+			langEntry := dir(langDir, 0, 1)
+			langDir = langEntry + entrySize
+			leaf := entries + dataSize*ii
+			le.PutUint32(buf[langEntry:], language)
+			le.PutUint32(buf[langEntry+4:], uint32(leaf))
+			// The address is relative to the section until relocated.
+			le.PutUint32(buf[leaf:], uint32(data))
+			le.PutUint32(buf[leaf+4:], uint32(len(r.data)))
+			relocs = append(relocs, uint32(leaf))
+			copy(buf[data:], r.data)
+			data = align(data+len(r.data), 8)
+		}
+	}
+	return buf, relocs
+}
+
+// writeObject writes a COFF object with section as its .rsrc section.
+func writeObject(w io.Writer, machine, reloc uint16, section []byte, relocs []uint32) error {
+	const (
+		headerSize  = 20
+		sectionSize = 40
+		relocSize   = 10
+		// IMAGE_SCN_CNT_INITIALIZED_DATA | IMAGE_SCN_MEM_READ
+		characteristics = 0x40000040
+		// IMAGE_SYM_CLASS_STATIC
+		classStatic = 3
+	)
+	var (
+		buf     = bytes.NewBuffer(nil)
+		le      = binary.LittleEndian
+		raw     = headerSize + sectionSize
+		relocAt = raw + len(section)
+		symbols = relocAt + relocSize*len(relocs)
+	)
+	header := make([]byte, headerSize+sectionSize)
+	le.PutUint16(header[0:], machine)
+	le.PutUint16(header[2:], 1)
+	le.PutUint32(header[8:], uint32(symbols))
+	le.PutUint32(header[12:], 1)
+	s := header[headerSize:]
+	copy(s, ".rsrc")
+	le.PutUint32(s[16:], uint32(len(section)))
+	le.PutUint32(s[20:], uint32(raw))
+	le.PutUint32(s[24:], uint32(relocAt))
+	le.PutUint16(s[32:], uint16(len(relocs)))
+	le.PutUint32(s[36:], characteristics)
+	buf.Write(header)
+	buf.Write(section)
+	for _, offset := range relocs {
+		var r [relocSize]byte
+		le.PutUint32(r[0:], offset)
+		// Relative to the section symbol, the first in the table.
+		le.PutUint32(r[4:], 0)
+		le.PutUint16(r[8:], reloc)
+		buf.Write(r[:])
+	}
+	var sym [18]byte
+	copy(sym[:], ".rsrc")
+	le.PutUint16(sym[12:], 1)
+	sym[16] = classStatic
+	buf.Write(sym[:])
+	// An empty string table is just its size.
+	var table [4]byte
+	le.PutUint32(table[:], 4)
+	buf.Write(table[:])
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// readResources walks the resource tree of a section loaded at base,
+// returning each leaf in directory order, so named entries come before id
+// entries of the same type. Named types are skipped.
+func readResources(section []byte, base uint32) ([]resource, error) {
+	var (
+		le        = binary.LittleEndian
+		resources []resource
+	)
+	// entries returns the entries of the directory at offset, as id and
+	// offset pairs.
+	entries := func(offset uint32, wantDir bool) ([][2]uint32, error) {
+		if int(offset)+16 > len(section) {
+			return nil, fmt.Errorf("resource directory at %#x out of bounds", offset)
+		}
+		var (
+			count = int(le.Uint16(section[offset+12:])) + int(le.Uint16(section[offset+14:]))
+			start = int(offset) + 16
+		)
+		if start+8*count > len(section) {
+			return nil, fmt.Errorf("resource directory at %#x truncated", offset)
+		}
+		list := make([][2]uint32, count)
+		for ii := range list {
+			var (
+				id     = le.Uint32(section[start+8*ii:])
+				target = le.Uint32(section[start+8*ii+4:])
+			)
+			if (target&(1<<31) != 0) != wantDir {
+				return nil, fmt.Errorf("resource directory at %#x has unexpected entry", offset)
+			}
+			list[ii] = [2]uint32{id, target &^ (1 << 31)}
+		}
+		return list, nil
+	}
+	types, err := entries(0, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		ids, err := entries(t[1], true)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			langs, err := entries(id[1], false)
+			if err != nil {
+				return nil, err
+			}
+			// Named types are of no use to icons.
+			if t[0]>>31 != 0 || len(langs) == 0 {
+				continue
+			}
+			var name string
+			if id[0]>>31 != 0 {
+				if name, err = readName(section, id[0]&^(1<<31)); err != nil {
+					return nil, err
+				}
+			}
+			leaf := langs[0][1]
+			if int(leaf)+16 > len(section) {
+				return nil, fmt.Errorf("resource data entry at %#x out of bounds", leaf)
+			}
+			var (
+				addr = le.Uint32(section[leaf:])
+				size = le.Uint32(section[leaf+4:])
+			)
+			if addr < base || uint64(addr-base)+uint64(size) > uint64(len(section)) {
+				return nil, fmt.Errorf("resource %d/%#x out of bounds", t[0], id[0])
+			}
+			r := resource{
+				typ:  uint16(t[0]),
+				name: name,
+				data: section[addr-base : addr-base+size],
+			}
+			if name == "" {
+				r.id = uint16(id[0])
+			}
+			resources = append(resources, r)
+		}
+	}
+	return resources, nil
+}
+
+// readName reads the name of a resource entry, stored at offset as a length
+// followed by that many utf-16 code units.
+func readName(section []byte, offset uint32) (string, error) {
+	if int(offset)+2 > len(section) {
+		return "", fmt.Errorf("resource name at %#x out of bounds", offset)
+	}
+	n := int(binary.LittleEndian.Uint16(section[offset:]))
+	if int(offset)+2+2*n > len(section) {
+		return "", fmt.Errorf("resource name at %#x truncated", offset)
+	}
+	units := make([]uint16, n)
+	for ii := range units {
+		units[ii] = binary.LittleEndian.Uint16(section[int(offset)+2+2*ii:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+func align(n, to int) int {
+	return (n + to - 1) / to * to
+}
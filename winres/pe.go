@@ -0,0 +1,115 @@
+package winres
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoIcon means a binary has no icon resources.
+var ErrNoIcon = errors.New("no icon resources")
+
+// ReadPE reads the icons of a Windows executable or dll, in resource order:
+// named groups first, by name, then numbered groups, so that the first is the
+// icon Windows shows for the file. Resource objects, such as those written by
+// WriteSyso, are read too.
+func ReadPE(r io.ReaderAt) ([]*IconGroup, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	section, base, err := resourceSection(f)
+	if err != nil {
+		return nil, err
+	}
+	if section == nil {
+		return nil, ErrNoIcon
+	}
+	resources, err := readResources(section, base)
+	if err != nil {
+		return nil, err
+	}
+	icons := map[uint16][]byte{}
+	for _, r := range resources {
+		// Groups refer to their images by id.
+		if r.typ == typeIcon && r.name == "" {
+			icons[r.id] = r.data
+		}
+	}
+	var groups []*IconGroup
+	for _, r := range resources {
+		if r.typ != typeGroupIcon {
+			continue
+		}
+		label := fmt.Sprint(r.id)
+		if r.name != "" {
+			label = fmt.Sprintf("%q", r.name)
+		}
+		g, err := readIconDir(r.data, 14)
+		if err != nil {
+			return nil, fmt.Errorf("icon group %s: %w", label, err)
+		}
+		for ii := range g.Images {
+			id := binary.LittleEndian.Uint16(r.data[6+14*ii+12:])
+			data, ok := icons[id]
+			if !ok {
+				return nil, fmt.Errorf("icon group %s: missing icon %d", label, id)
+			}
+			g.Images[ii].Data = data
+		}
+		groups = append(groups, g)
+	}
+	if len(groups) == 0 {
+		return nil, ErrNoIcon
+	}
+	return groups, nil
+}
+
+// resourceSection returns the resource tree of f, from the resource data
+// directory, and the address it is loaded at. Objects have no optional
+// header, so the .rsrc section is used, with addresses relative to it.
+func resourceSection(f *pe.File) ([]byte, uint32, error) {
+	var dir pe.DataDirectory
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if h.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dir = h.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	case *pe.OptionalHeader64:
+		if h.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dir = h.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	default:
+		s := f.Section(".rsrc")
+		if s == nil {
+			return nil, 0, nil
+		}
+		data, err := s.Data()
+		return data, 0, err
+	}
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, 0, nil
+	}
+	for _, s := range f.Sections {
+		if dir.VirtualAddress < s.VirtualAddress || dir.VirtualAddress >= s.VirtualAddress+s.VirtualSize {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, 0, err
+		}
+		// Raw data is padded beyond the section, or may stop short of it.
+		if uint32(len(data)) > s.VirtualSize {
+			data = data[:s.VirtualSize]
+		}
+		offset := dir.VirtualAddress - s.VirtualAddress
+		if offset >= uint32(len(data)) {
+			return nil, 0, errors.New("resource directory out of bounds")
+		}
+		return data[offset:], dir.VirtualAddress, nil
+	}
+	return nil, 0, errors.New("resource directory outside any section")
+}
@@ -0,0 +1,149 @@
+package winres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReadPE(t *testing.T) {
+	t.Parallel()
+	var (
+		app   = testGroup()
+		small = &IconGroup{Images: []IconImage{
+			{Width: 16, Height: 16, Planes: 1, BitCount: 32, Data: bytes.Repeat([]byte{3}, 20)},
+		}}
+		named = &IconGroup{Images: []IconImage{
+			{Width: 48, Height: 48, Planes: 1, BitCount: 32, Data: bytes.Repeat([]byte{4}, 30)},
+		}}
+		other = &IconGroup{Images: []IconImage{
+			{Width: 24, Height: 24, Planes: 1, BitCount: 32, Data: bytes.Repeat([]byte{5}, 25)},
+		}}
+		resources = []resource{
+			// A version resource, which is ignored.
+			{typ: 16, id: 1, data: []byte("version")},
+			{typ: typeGroupIcon, id: 2, data: small.groupResource(4)},
+			{typ: typeGroupIcon, id: 1, data: app.groupResource(1)},
+			// Named groups come first, ordered by name regardless of case.
+			{typ: typeGroupIcon, name: "zicon", data: other.groupResource(6)},
+			{typ: typeGroupIcon, name: "MAINICON", data: named.groupResource(5)},
+		}
+	)
+	for ii, img := range append(append(append(app.Images, small.Images...), named.Images...), other.Images...) {
+		resources = append(resources, resource{typ: typeIcon, id: uint16(ii + 1), data: img.Data})
+	}
+	for _, wide := range []bool{false, true} {
+		desc := "pe32"
+		if wide {
+			desc = "pe32+"
+		}
+		t.Run(desc, func(st *testing.T) {
+			groups, err := ReadPE(bytes.NewReader(peFile(wide, resources)))
+			if err != nil {
+				st.Fatalf("reading: %v", err)
+			}
+			want := []*IconGroup{named, other, app, small}
+			if !reflect.DeepEqual(groups, want) {
+				st.Fatalf("want %+v, got %+v", want, groups)
+			}
+		})
+	}
+}
+
+func TestReadPESyso(t *testing.T) {
+	t.Parallel()
+	var (
+		want = testGroup()
+		buf  = bytes.NewBuffer(nil)
+	)
+	if err := want.WriteSyso(buf, "arm64"); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	groups, err := ReadPE(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if len(groups) != 1 || !reflect.DeepEqual(groups[0], want) {
+		t.Fatalf("want %+v, got %+v", want, groups)
+	}
+}
+
+func TestReadPEInvalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc      string
+		resources []resource
+		err       error
+	}{
+		{"no resources", nil, ErrNoIcon},
+		{"no icons", []resource{{typ: 16, id: 1, data: []byte("version")}}, ErrNoIcon},
+		{"missing icon", []resource{{typ: typeGroupIcon, id: 1, data: testGroup().groupResource(1)}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(st *testing.T) {
+			_, err := ReadPE(bytes.NewReader(peFile(true, tt.resources)))
+			if err == nil {
+				st.Fatalf("want error, got nil")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				st.Fatalf("want %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// peFile returns a minimal executable, pe32+ if wide, whose only section
+// holds resources. No resources means no resource directory.
+func peFile(wide bool, resources []resource) []byte {
+	const (
+		lfanew = 64
+		rva    = 0x1000
+		raw    = 0x200
+	)
+	var (
+		le         = binary.LittleEndian
+		optional   = 224
+		dirs       = 96
+		magic      = uint16(0x10b)
+		machine    = uint16(0x14c)
+		section    []byte
+		relocs     []uint32
+		headerSize = lfanew + 4 + 20
+	)
+	if wide {
+		optional, dirs, magic, machine = 240, 112, 0x20b, 0x8664
+	}
+	if len(resources) > 0 {
+		section, relocs = writeResources(resources)
+		for _, offset := range relocs {
+			le.PutUint32(section[offset:], le.Uint32(section[offset:])+rva)
+		}
+	}
+	buf := make([]byte, raw+align(len(section), raw))
+	copy(buf, "MZ")
+	le.PutUint32(buf[0x3c:], lfanew)
+	copy(buf[lfanew:], "PE\x00\x00")
+	header := buf[lfanew+4:]
+	le.PutUint16(header[0:], machine)
+	le.PutUint16(header[2:], 1)
+	le.PutUint16(header[16:], uint16(optional))
+	le.PutUint16(header[18:], 0x22)
+	opt := buf[headerSize:]
+	le.PutUint16(opt[0:], magic)
+	le.PutUint32(opt[dirs-4:], 16)
+	if len(section) > 0 {
+		le.PutUint32(opt[dirs+16:], rva)
+		le.PutUint32(opt[dirs+20:], uint32(len(section)))
+	}
+	s := buf[headerSize+optional:]
+	copy(s, ".rsrc")
+	le.PutUint32(s[8:], uint32(len(section)))
+	le.PutUint32(s[12:], rva)
+	le.PutUint32(s[16:], uint32(align(len(section), raw)))
+	le.PutUint32(s[20:], raw)
+	le.PutUint32(s[36:], 0x40000040)
+	copy(buf[raw:], section)
+	return buf
+}
@@ -0,0 +1,145 @@
+// Package winres reads and writes the icon resources of Windows binaries.
+//
+// An icon is stored as an RT_GROUP_ICON resource, a copy of the ICO
+// directory listing each image, and an RT_ICON resource per image.
+package winres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Resource types.
+const (
+	typeIcon      = 3
+	typeGroupIcon = 14
+)
+
+// IconGroup is an icon made of several images, as stored in an ICO file or
+// in the resources of a Windows binary.
+type IconGroup struct {
+	Images []IconImage
+}
+
+// IconImage is an image of an IconGroup, as stored: a png or a bmp without a
+// file header.
+type IconImage struct {
+	// Width and Height in px, where 0 means 256.
+	Width, Height uint8
+	ColorCount    uint8
+	Planes        uint16
+	BitCount      uint16
+	Data          []byte
+}
+
+// Size returns the width of the image in px, reading 0 as 256.
+func (img IconImage) Size() int {
+	if img.Width == 0 {
+		return 256
+	}
+	return int(img.Width)
+}
+
+// ReadICO reads the images of an ICO file.
+func ReadICO(r io.Reader) (*IconGroup, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := readIconDir(data, 16)
+	if err != nil {
+		return nil, err
+	}
+	for ii := range dir.Images {
+		var (
+			entry  = data[6+16*ii:]
+			size   = int(binary.LittleEndian.Uint32(entry[8:]))
+			offset = int(binary.LittleEndian.Uint32(entry[12:]))
+		)
+		if size < 0 || offset < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("ico image %d out of bounds", ii)
+		}
+		dir.Images[ii].Data = data[offset : offset+size]
+	}
+	return dir, nil
+}
+
+// WriteICO writes g as an ICO file.
+func (g *IconGroup) WriteICO(w io.Writer) error {
+	var (
+		buf    = bytes.NewBuffer(nil)
+		offset = 6 + 16*len(g.Images)
+	)
+	g.writeDir(buf, func(ii int) []byte {
+		var entry [8]byte
+		binary.LittleEndian.PutUint32(entry[0:], uint32(len(g.Images[ii].Data)))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(offset))
+		offset += len(g.Images[ii].Data)
+		return entry[:]
+	})
+	for _, img := range g.Images {
+		buf.Write(img.Data)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// groupResource returns the RT_GROUP_ICON resource of g, where the images
+// are the RT_ICON resources numbered from first.
+func (g *IconGroup) groupResource(first uint16) []byte {
+	buf := bytes.NewBuffer(nil)
+	g.writeDir(buf, func(ii int) []byte {
+		var entry [6]byte
+		binary.LittleEndian.PutUint32(entry[0:], uint32(len(g.Images[ii].Data)))
+		binary.LittleEndian.PutUint16(entry[4:], first+uint16(ii))
+		return entry[:]
+	})
+	return buf.Bytes()
+}
+
+// writeDir writes the directory shared by ICO files and RT_GROUP_ICON
+// resources, where each entry ends with where to find its image.
+func (g *IconGroup) writeDir(buf *bytes.Buffer, locate func(ii int) []byte) {
+	var header [6]byte
+	binary.LittleEndian.PutUint16(header[2:], 1)
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(g.Images)))
+	buf.Write(header[:])
+	for ii, img := range g.Images {
+		var entry [8]byte
+		entry[0], entry[1], entry[2] = img.Width, img.Height, img.ColorCount
+		binary.LittleEndian.PutUint16(entry[4:], img.Planes)
+		binary.LittleEndian.PutUint16(entry[6:], img.BitCount)
+		buf.Write(entry[:])
+		buf.Write(locate(ii))
+	}
+}
+
+// readIconDir reads the directory of an ICO file or RT_GROUP_ICON resource,
+// with entries of the given size, leaving the image data empty.
+func readIconDir(data []byte, entrySize int) (*IconGroup, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data) != 0 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, errors.New("invalid icon directory")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 {
+		return nil, errors.New("icon has no images")
+	}
+	if len(data) < 6+entrySize*count {
+		return nil, errors.New("icon directory truncated")
+	}
+	g := &IconGroup{Images: make([]IconImage, count)}
+	for ii := range g.Images {
+		entry := data[6+entrySize*ii:]
+		g.Images[ii] = IconImage{
+			Width:      entry[0],
+			Height:     entry[1],
+			ColorCount: entry[2],
+			Planes:     binary.LittleEndian.Uint16(entry[4:]),
+			BitCount:   binary.LittleEndian.Uint16(entry[6:]),
+		}
+	}
+	return g, nil
+}
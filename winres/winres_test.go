@@ -0,0 +1,212 @@
+package winres
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testGroup returns an icon with images of distinct content.
+func testGroup() *IconGroup {
+	return &IconGroup{Images: []IconImage{
+		{Width: 0, Height: 0, Planes: 1, BitCount: 32, Data: []byte("\x89PNG\r\n\x1a\n256")},
+		{Width: 32, Height: 32, Planes: 1, BitCount: 32, Data: bytes.Repeat([]byte{1}, 37)},
+		{Width: 16, Height: 16, ColorCount: 16, Planes: 1, BitCount: 4, Data: bytes.Repeat([]byte{2}, 12)},
+	}}
+}
+
+func TestICORoundTrip(t *testing.T) {
+	t.Parallel()
+	var (
+		want = testGroup()
+		buf  = bytes.NewBuffer(nil)
+	)
+	if err := want.WriteICO(buf); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	got, err := ReadICO(buf)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestReadICOInvalid(t *testing.T) {
+	t.Parallel()
+	for _, data := range [][]byte{
+		nil,
+		{0, 0, 2, 0, 1, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 1, 0, 1, 0, 16, 16},
+		append([]byte{0, 0, 1, 0, 1, 0, 16, 16, 0, 0, 1, 0, 32, 0, 0xff, 0, 0, 0, 22, 0, 0, 0}, 1, 2, 3),
+	} {
+		if _, err := ReadICO(bytes.NewReader(data)); err == nil {
+			t.Errorf("%v: want error, got nil", data)
+		}
+	}
+}
+
+func TestWriteSyso(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		arch    string
+		machine uint16
+		reloc   uint16
+	}{
+		{"amd64", pe.IMAGE_FILE_MACHINE_AMD64, 3},
+		{"386", pe.IMAGE_FILE_MACHINE_I386, 7},
+		{"arm64", pe.IMAGE_FILE_MACHINE_ARM64, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch, func(st *testing.T) {
+			var (
+				group = testGroup()
+				buf   = bytes.NewBuffer(nil)
+			)
+			if err := group.WriteSyso(buf, tt.arch); err != nil {
+				st.Fatalf("writing: %v", err)
+			}
+			f, err := pe.NewFile(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				st.Fatalf("parsing object: %v", err)
+			}
+			if f.Machine != tt.machine {
+				st.Errorf("want machine %#x, got %#x", tt.machine, f.Machine)
+			}
+			if len(f.Sections) != 1 || f.Sections[0].Name != ".rsrc" {
+				st.Fatalf("want a single .rsrc section, got %v", f.Sections)
+			}
+			if len(f.Symbols) != 1 || f.Symbols[0].Name != ".rsrc" || f.Symbols[0].SectionNumber != 1 {
+				st.Errorf("want a .rsrc section symbol, got %+v", f.Symbols)
+			}
+			section := f.Sections[0]
+			data, err := section.Data()
+			if err != nil {
+				st.Fatalf("reading section: %v", err)
+			}
+			// One relocation per data entry, addressing the section.
+			if len(section.Relocs) != len(group.Images)+1 {
+				st.Fatalf("want %d relocations, got %d", len(group.Images)+1, len(section.Relocs))
+			}
+			for _, r := range section.Relocs {
+				if r.Type != tt.reloc || r.SymbolTableIndex != 0 {
+					st.Errorf("unexpected relocation %+v", r)
+				}
+				if addr := binary.LittleEndian.Uint32(data[r.VirtualAddress:]); int(addr) >= len(data) {
+					st.Errorf("relocated address %#x outside section", addr)
+				}
+			}
+			resources, err := readResources(data, 0)
+			if err != nil {
+				st.Fatalf("reading resources: %v", err)
+			}
+			if len(resources) != len(group.Images)+1 {
+				st.Fatalf("want %d resources, got %d", len(group.Images)+1, len(resources))
+			}
+			for ii, img := range group.Images {
+				r := resources[ii]
+				if r.typ != typeIcon || r.id != uint16(ii+1) || !bytes.Equal(r.data, img.Data) {
+					st.Errorf("icon %d: got type %d id %d", ii, r.typ, r.id)
+				}
+			}
+			last := resources[len(resources)-1]
+			if last.typ != typeGroupIcon || last.id != 1 {
+				st.Fatalf("want group icon 1, got type %d id %d", last.typ, last.id)
+			}
+			dir, err := readIconDir(last.data, 14)
+			if err != nil {
+				st.Fatalf("reading group: %v", err)
+			}
+			for ii, img := range group.Images {
+				entry := last.data[6+14*ii:]
+				if got := dir.Images[ii]; got.Width != img.Width || got.BitCount != img.BitCount {
+					st.Errorf("group entry %d: want %+v, got %+v", ii, img, got)
+				}
+				if size := binary.LittleEndian.Uint32(entry[8:]); size != uint32(len(img.Data)) {
+					st.Errorf("group entry %d: want size %d, got %d", ii, len(img.Data), size)
+				}
+				if id := binary.LittleEndian.Uint16(entry[12:]); id != uint16(ii+1) {
+					st.Errorf("group entry %d: want id %d, got %d", ii, ii+1, id)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteSysoInvalid(t *testing.T) {
+	t.Parallel()
+	if err := testGroup().WriteSyso(bytes.NewBuffer(nil), "riscv64"); err == nil {
+		t.Errorf("unsupported architecture: want error, got nil")
+	}
+	if err := (&IconGroup{}).WriteSyso(bytes.NewBuffer(nil), "amd64"); err == nil {
+		t.Errorf("empty icon: want error, got nil")
+	}
+}
+
+// TestWriteSysoLinks builds a Windows program with the syso for each
+// architecture, checking that the linker embeds the icon.
+func TestWriteSysoLinks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program per architecture")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+	t.Parallel()
+	want := testGroup()
+	for _, arch := range Arches {
+		arch := arch
+		t.Run(arch, func(st *testing.T) {
+			st.Parallel()
+			dir := st.TempDir()
+			files := map[string]string{
+				"go.mod":  "module app\n\ngo 1.21\n",
+				"main.go": "package main\n\nfunc main() {}\n",
+			}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					st.Fatal(err)
+				}
+			}
+			syso := bytes.NewBuffer(nil)
+			if err := want.WriteSyso(syso, arch); err != nil {
+				st.Fatalf("writing: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "icon_windows_"+arch+".syso"), syso.Bytes(), 0644); err != nil {
+				st.Fatal(err)
+			}
+			cmd := exec.Command(gobin, "build", "-o", "app.exe", ".")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(),
+				"GOOS=windows",
+				"GOARCH="+arch,
+				"CGO_ENABLED=0",
+				"GOWORK=off",
+				"GOFLAGS=",
+			)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				st.Fatalf("building: %v\n%s", err, out)
+			}
+			exe, err := os.Open(filepath.Join(dir, "app.exe"))
+			if err != nil {
+				st.Fatal(err)
+			}
+			defer exe.Close()
+			groups, err := ReadPE(exe)
+			if err != nil {
+				st.Fatalf("reading: %v", err)
+			}
+			if len(groups) != 1 || !reflect.DeepEqual(groups[0], want) {
+				st.Fatalf("want %+v, got %+v", want, groups)
+			}
+		})
+	}
+}
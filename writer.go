@@ -2,21 +2,43 @@ package icns
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/png"
 	"io"
+	"sort"
+	"sync"
 )
 
 // Icon encodes an icns icon.
 type Icon struct {
-	Type  OsType
+	Type OsType
+	// Image is read-only once the icon is encoded, which happens on the first
+	// WriteTo or when an Encoder or NewPNGIcon creates the icon: the encoded
+	// png is kept, so later changes to Image are not written. Create a new
+	// Icon to change the artwork.
 	Image image.Image
+	// Upscaled reports whether Image is larger than the source it came from.
+	Upscaled bool
 
 	header    [8]byte
 	headerSet bool
 	data      []byte
 }
 
+// NewPNGIcon creates an icon of type t that is written as data, a png of the
+// type's size, without encoding it again.
+func NewPNGIcon(t OsType, data []byte) (*Icon, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if size := img.Bounds().Size(); size.X != int(t.Size) || size.Y != int(t.Size) {
+		return nil, fmt.Errorf("%s: want %dx%d, got %dx%d", t.ID, t.Size, t.Size, size.X, size.Y)
+	}
+	return &Icon{Type: t, Image: img, data: data}, nil
+}
+
 // WriteTo encodes the icon into wr.
 func (i *Icon) WriteTo(wr io.Writer) (int64, error) {
 	var written int64
@@ -48,9 +70,25 @@ func (i *Icon) encodeImage() error {
 	return nil
 }
 
+// pngEncoder reuses its compression buffers between icons.
+var pngEncoder = &png.Encoder{BufferPool: &bufferPool{}}
+
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func (p *bufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *bufferPool) Put(b *png.EncoderBuffer) {
+	p.pool.Put(b)
+}
+
 func encodeImage(img image.Image) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
-	if err := png.Encode(buf, img); err != nil {
+	if err := pngEncoder.Encode(buf, img); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
@@ -78,6 +116,9 @@ func (i *Icon) writeData(wr io.Writer) (int64, error) {
 // IconSet encodes a set of icons into an ICNS file.
 type IconSet struct {
 	Icons []*Icon
+	// Stats summarises how the set was created, if it was created by an
+	// Encoder.
+	Stats Stats
 
 	header    [8]byte
 	headerSet bool
@@ -103,11 +144,58 @@ func (s *IconSet) WriteTo(wr io.Writer) (int64, error) {
 	return written, nil
 }
 
+// Upscaled returns the types of the icons that were upscaled from the source.
+func (s *IconSet) Upscaled() []OsType {
+	var types []OsType
+	for _, icon := range s.Icons {
+		if icon != nil && icon.Upscaled {
+			types = append(types, icon.Type)
+		}
+	}
+	return types
+}
+
+// checkBudget returns ErrSizeBudget if the encoded icns is larger than budget.
+func (s *IconSet) checkBudget(budget int) error {
+	var (
+		size    = 8
+		entries []EntrySize
+	)
+	for _, icon := range s.Icons {
+		if icon == nil {
+			continue
+		}
+		size += len(icon.data) + 8
+		entries = append(entries, EntrySize{Type: icon.Type, Bytes: len(icon.data) + 8})
+	}
+	if size <= budget {
+		return nil
+	}
+	sort.SliceStable(entries, func(ii, jj int) bool {
+		return entries[ii].Bytes > entries[jj].Bytes
+	})
+	err := ErrSizeBudget{Budget: budget, Size: size}
+	for _, e := range entries {
+		if size <= budget {
+			break
+		}
+		err.Over = append(err.Over, e)
+		size -= e.Bytes
+	}
+	return err
+}
+
 func (s *IconSet) encodeIcons() error {
 	if len(s.data) > 0 {
 		return nil
 	}
-	buf := bytes.NewBuffer(nil)
+	var size int
+	for _, icon := range s.Icons {
+		if icon != nil {
+			size += len(icon.data) + 8
+		}
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size))
 	for _, icon := range s.Icons {
 		if icon == nil {
 			continue